@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockedPasswordPrefix is put in front of the userPassword of disabled accounts, which makes every bind fail while the
+// original hash is kept for when the account is enabled again
+const lockedPasswordPrefix = "!"
+
+// permanentLockTime is the pwdAccountLockedTime of accounts locked until an administrator unlocks them (ppolicy)
+// other values are set by the password policy after failed logins and are left untouched
+const permanentLockTime = "000001010000Z"
+
+// shadowEpoch is the day shadowExpire counts from
+var shadowEpoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// expired returns true if the account is past its expiry date at the given time
+func (u *posixAccount) expired(t time.Time) bool {
+	return u.Expires != nil && !t.Before(*u.Expires)
+}
+
+// isDisabled returns true if the account is disabled explicitly or because it expired
+func (u *posixAccount) isDisabled(t time.Time) bool {
+	return (u.Disabled != nil && *u.Disabled) || u.expired(t)
+}
+
+// isLocked returns true if the account is locked permanently
+func (u *posixAccount) isLocked() bool {
+	return u.Locked != nil && *u.Locked
+}
+
+// disabledReason describes why an account is disabled at the given time or returns an empty string
+func (u *posixAccount) disabledReason(t time.Time) string {
+	if u.Disabled != nil && *u.Disabled {
+		return "account is disabled"
+	}
+
+	if u.expired(t) {
+		return fmt.Sprintf("account expired on %s", formatTime(*u.Expires))
+	}
+
+	return ""
+}
+
+// ldapPassword returns the userPassword of a local account as it is written to LDAP
+func ldapPassword(user *posixAccount) string {
+	if user.isDisabled(now) {
+		return lockedPasswordPrefix + *user.UserPassword
+	}
+
+	return *user.UserPassword
+}
+
+// shadowExpireValue returns a date as number of days since 1970-01-01 as used by shadowExpire
+func shadowExpireValue(t time.Time) string {
+	return strconv.Itoa(int(t.Sub(shadowEpoch).Hours() / 24))
+}
+
+// parseShadowExpire returns the date of a shadowExpire value, nil if it isn't set (-1 or invalid)
+func parseShadowExpire(value string) *time.Time {
+	var (
+		days int
+		date time.Time
+		err  error
+	)
+
+	if days, err = strconv.Atoi(value); err != nil || days < 0 {
+		return nil
+	}
+
+	date = shadowEpoch.AddDate(0, 0, days)
+
+	return &date
+}
+
+// sameExpiry returns true if both expiry dates are unset or on the same day as shadowExpire only stores days
+func sameExpiry(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return shadowExpireValue(*a) == shadowExpireValue(*b)
+}
+
+// accountState returns the state of an account for diff and audit output
+func accountState(user *posixAccount) string {
+	var state []string
+
+	if user.isDisabled(now) {
+		state = append(state, "disabled")
+	}
+
+	if user.isLocked() {
+		state = append(state, "locked")
+	}
+
+	if len(state) == 0 {
+		return "active"
+	}
+
+	return strings.Join(state, ", ")
+}
+
+// pending returns true if the start date of the account hasn't been reached at the given time
+func (u *posixAccount) pending(t time.Time) bool {
+	return u.StartDate != nil && t.Before(*u.StartDate)
+}
+
+// pendingReason describes why an account isn't provisioned yet or returns an empty string
+func (u *posixAccount) pendingReason(t time.Time) string {
+	if !u.pending(t) {
+		return ""
+	}
+
+	return fmt.Sprintf("account starts on %s", formatTime(*u.StartDate))
+}
+
+// findObject returns the account of a people group with the given username or nil
+func (g posixGroup) findObject(uid string) *posixAccount {
+	var i int
+
+	for i = range g.Objects {
+		if g.Objects[i].UID != nil && *g.Objects[i].UID == uid {
+			return &g.Objects[i]
+		}
+	}
+
+	return nil
+}
+
+// pendingAccounts returns all local accounts whose start date hasn't been reached yet, ordered by start date
+func pendingAccounts() []*posixAccount {
+	var (
+		dn       string
+		i        int
+		accounts []*posixAccount
+	)
+
+	for dn = range localPeople {
+		for i = range localPeople[dn].Objects {
+			if localPeople[dn].Objects[i].pending(now) {
+				accounts = append(accounts, &localPeople[dn].Objects[i])
+			}
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		if !accounts[i].StartDate.Equal(*accounts[j].StartDate) {
+			return accounts[i].StartDate.Before(*accounts[j].StartDate)
+		}
+
+		return *accounts[i].UID < *accounts[j].UID
+	})
+
+	return accounts
+}
+
+// pendingMemberships returns the DNs of all groups a pending account joins on its start date
+func pendingMemberships(user *posixAccount) []string {
+	var (
+		dn     string
+		groups []string
+	)
+
+	for dn = range localPeople {
+		if strings.HasSuffix(strings.ToLower(user.dn), ","+strings.ToLower(dn)) {
+			groups = append(groups, dn)
+		}
+	}
+
+	for dn = range localGroups {
+		if containsFold(localGroups[dn].memberDNs, user.dn) {
+			groups = append(groups, dn)
+		}
+	}
+
+	for dn = range localUnixGroups {
+		if containsString(localUnixGroups[dn].memberUIDs, *user.UID) {
+			groups = append(groups, dn)
+		}
+	}
+
+	sort.Strings(groups)
+
+	return groups
+}
+
+// pendingMember returns true if a member DN belongs to a local account that hasn't started yet
+func pendingMember(dn string) bool {
+	var user *posixAccount
+
+	if user = findUserByDN(dn); user != nil {
+		return user.pending(now)
+	}
+
+	return false
+}
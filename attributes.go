@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// attributeNameRegex defines valid LDAP attribute names (descr in RFC 4512)
+var attributeNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+
+// reservedAttributes contains all attributes Monban manages itself which therefore can't be declared in the schema
+var reservedAttributes = []string{
+	"objectClass", "cn", "ou", "uid", "uidNumber", "gidNumber", "givenName", "sn", "displayName", "loginShell", "mail",
+	"homeDirectory", "userPassword", "sshPublicKey", "description", "member", "memberUid",
+	"owner", "shadowExpire", "pwdAccountLockedTime",
+}
+
+// schemaObjectTypes maps object type names used in the schema config to their internal object type
+var schemaObjectTypes = map[string]int{
+	"posixAccount": objectTypePosixAccount,
+	"groupOfNames": objectTypeGroupOfNames,
+}
+
+// attributeValues contains the values of an additional LDAP attribute
+// a single value can be written as scalar, multiple values as list
+type attributeValues []string
+
+// UnmarshalYAML accepts a scalar or a list of scalars
+func (a *attributeValues) UnmarshalYAML(node *yaml.Node) error {
+	var i int
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*a = attributeValues{node.Value}
+
+	case yaml.SequenceNode:
+		*a = make(attributeValues, 0, len(node.Content))
+
+		for i = range node.Content {
+			if node.Content[i].Kind != yaml.ScalarNode {
+				return &yaml.TypeError{Errors: []string{
+					fmt.Sprintf("line %d: attribute values must be strings", node.Content[i].Line)}}
+			}
+
+			*a = append(*a, node.Content[i].Value)
+		}
+
+	default:
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: expected a value or a list of values", node.Line)}}
+	}
+
+	return nil
+}
+
+// equal returns true if a and b contain the same values regardless of their order
+func (a attributeValues) equal(b attributeValues) bool {
+	var (
+		sortedA []string
+		sortedB []string
+		i       int
+	)
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA = append([]string(nil), a...)
+	sortedB = append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	for i = range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkAttributeSchema verifies the attributes declared in the schema config
+// node is the mapping node of schema.attributes and only used for error positions
+func checkAttributeSchema(node *yaml.Node) {
+	var (
+		names    []string
+		name     string
+		other    string
+		reserved string
+		schema   *attributeSchema
+		i        int
+		ok       bool
+		objType  int
+	)
+
+	for name = range config.Schema.Attributes {
+		names = append(names, name)
+	}
+
+	// report errors in a stable order
+	sort.Strings(names)
+
+	for _, name = range names {
+		schema = config.Schema.Attributes[name]
+
+		if !attributeNameRegex.MatchString(name) {
+			addConfigError(configFile, yamlKeyPosition(node, name), "invalid attribute name '%s'", name)
+		}
+
+		for _, reserved = range reservedAttributes {
+			if strings.EqualFold(name, reserved) || strings.EqualFold(name, ldapAttribute(reserved)) {
+				addConfigError(configFile, yamlKeyPosition(node, name), "attribute %s is managed by Monban and can't be declared in schema", name)
+			}
+		}
+
+		// attribute names are case insensitive in LDAP
+		for _, other = range names {
+			if other < name && strings.EqualFold(name, other) {
+				addConfigError(configFile, yamlKeyPosition(node, name), "attribute %s is already declared as %s", name, other)
+			}
+		}
+
+		// an attribute without any settings is a single valued attribute of people objects
+		if schema == nil {
+			schema = new(attributeSchema)
+			config.Schema.Attributes[name] = schema
+		}
+
+		if len(schema.ObjectTypes) == 0 {
+			schema.ObjectTypes = []string{"posixAccount"}
+		}
+
+		schema.objectTypes = make(map[int]bool)
+
+		for i = range schema.ObjectTypes {
+			if objType, ok = schemaObjectTypes[schema.ObjectTypes[i]]; !ok {
+				addConfigError(configFile, yamlSequenceItem(yamlMappingValue(yamlMappingValue(node, name), "object_types"), i),
+					"unknown object type '%s', supported object types are posixAccount, groupOfNames", schema.ObjectTypes[i])
+				continue
+			}
+
+			schema.objectTypes[objType] = true
+		}
+	}
+}
+
+// schemaAttributes returns the names of all additional attributes declared for an object type in alphabetical order
+func schemaAttributes(objectType int) []string {
+	var (
+		names  []string
+		name   string
+		schema *attributeSchema
+	)
+
+	for name, schema = range config.Schema.Attributes {
+		if schema.objectTypes[objectType] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// schemaAttributeName returns the name an attribute is declared with in the schema config or an empty string if the
+// attribute isn't declared for the given object type
+func schemaAttributeName(objectType int, name string) string {
+	var candidate string
+
+	for _, candidate = range schemaAttributes(objectType) {
+		if strings.EqualFold(candidate, name) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// checkAttributes verifies the additional attributes of an object against the schema and renders templated values
+// node is the mapping node of the attributes and only used for error positions; attribute names are rewritten to the
+// spelling used in the schema config
+func checkAttributes(file string, node *yaml.Node, attributes map[string]attributeValues, objectType int,
+	data map[string]interface{}) {
+	var (
+		name     string
+		declared string
+		values   attributeValues
+		schema   *attributeSchema
+		rendered string
+		i        int
+		err      error
+	)
+
+	// names can be rewritten below, so iterate over a copy of them
+	for _, name = range sortedAttributeNames(attributes) {
+		values = attributes[name]
+
+		if declared = schemaAttributeName(objectType, name); declared == "" {
+			addConfigError(file, yamlKeyPosition(node, name), "attribute %s is not declared in schema for %s objects", name,
+				objectTypeName(objectType))
+			delete(attributes, name)
+			continue
+		}
+
+		schema = config.Schema.Attributes[declared]
+
+		// attributes set without any value are not set at all
+		if len(values) == 0 {
+			delete(attributes, name)
+			continue
+		}
+
+		if !schema.MultiValued && len(values) > 1 {
+			addConfigError(file, yamlPosition(node, name), "attribute %s is single valued but has %d values", declared,
+				len(values))
+		}
+
+		for i = range values {
+			if values[i] == "" {
+				addConfigError(file, yamlPosition(node, name), "attribute %s must not have empty values", declared)
+				continue
+			}
+
+			if !schema.Templated {
+				continue
+			}
+
+			if rendered, err = renderTemplate(values[i], data); err != nil {
+				addConfigError(file, yamlPosition(node, name), "failed to render template of attribute %s: %s", declared,
+					err.Error())
+				continue
+			}
+
+			if rendered == "" {
+				addConfigError(file, yamlPosition(node, name), "template of attribute %s renders to an empty value", declared)
+				continue
+			}
+
+			values[i] = rendered
+		}
+
+		if declared != name {
+			delete(attributes, name)
+		}
+
+		attributes[declared] = values
+	}
+}
+
+// compareAttributes returns the additional attributes that differ between local and remote objects with their new
+// values; an empty list of values means the attribute is to be deleted. Returns nil if there is no difference.
+func compareAttributes(local map[string]attributeValues, remote map[string]attributeValues,
+	objectType int) map[string]attributeValues {
+	var (
+		diff map[string]attributeValues
+		name string
+	)
+
+	for _, name = range schemaAttributes(objectType) {
+		if local[name].equal(remote[name]) {
+			continue
+		}
+
+		if diff == nil {
+			diff = make(map[string]attributeValues)
+		}
+
+		// never nil to tell apart from unchanged attributes
+		diff[name] = append(attributeValues{}, local[name]...)
+	}
+
+	return diff
+}
+
+// addAttributes adds all additional attributes with values to a LDAP add request
+func addAttributes(add *ldap.AddRequest, attributes map[string]attributeValues) {
+	var name string
+
+	for _, name = range sortedAttributeNames(attributes) {
+		if len(attributes[name]) > 0 {
+			add.Attribute(name, attributes[name])
+		}
+	}
+}
+
+// replaceAttributes replaces all given additional attributes within a LDAP modify request
+// attributes without values are deleted
+func replaceAttributes(modify *ldap.ModifyRequest, attributes map[string]attributeValues) {
+	var name string
+
+	for _, name = range sortedAttributeNames(attributes) {
+		modify.Replace(name, attributes[name])
+	}
+}
+
+// printAttributes pretty prints additional attributes for diff and audit output
+func printAttributes(indent string, attributes map[string]attributeValues) {
+	var name string
+
+	for _, name = range sortedAttributeNames(attributes) {
+		if len(attributes[name]) == 0 {
+			fmt.Printf("%s%s: *to be deleted*\n", indent, name)
+			continue
+		}
+
+		fmt.Printf("%s%s: %s\n", indent, name, strings.Join(attributes[name], ", "))
+	}
+}
+
+// sortedAttributeNames returns the names of the given attributes in alphabetical order
+func sortedAttributeNames(attributes map[string]attributeValues) []string {
+	var (
+		names []string
+		name  string
+	)
+
+	for name = range attributes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// objectTypeName returns the name of an object type as used in config files
+func objectTypeName(objectType int) string {
+	var (
+		name string
+		t    int
+	)
+
+	for name, t = range schemaObjectTypes {
+		if t == objectType {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,904 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// auditSources contains all sources an audit report can be built from
+var auditSources = []string{"file", "ldap", "both"}
+
+// auditViews contains all views of the audit: users with their groups or groups with their members
+var auditViews = []string{"users", "groups"}
+
+// auditReport is the model all audit output formats are rendered from
+// all lists are sorted to give the same output for the same config
+type auditReport struct {
+	// Source is file, ldap or both
+	Source   string           `json:"source"`
+	Users    []*auditUser     `json:"users"`
+	Groups   []*auditGroup    `json:"groups"`
+	Roles    []*auditRole     `json:"roles,omitempty"`
+	Expiring []*auditExpiring `json:"expiring_memberships,omitempty"`
+	// ExpiryWarningDays is the window used for Expiring
+	ExpiryWarningDays int `json:"expiry_warning_days"`
+	// EmptyGroups and UsersWithoutMemberships contain the DNs of groups without any member and the usernames of users
+	// without any membership besides the posixGroup of their people file
+	EmptyGroups             []string `json:"empty_groups"`
+	UsersWithoutMemberships []string `json:"users_without_memberships"`
+}
+
+// auditUser contains a user object and all of its memberships
+type auditUser struct {
+	Username    string `json:"username"`
+	DN          string `json:"dn"`
+	PosixGroup  string `json:"posix_group"`
+	GivenName   string `json:"given_name"`
+	Surname     string `json:"surname"`
+	DisplayName string `json:"display_name"`
+	LoginShell  string `json:"login_shell"`
+	Mail        string `json:"mail"`
+	HomeDir     string `json:"home_dir"`
+	// Sources contains where attribute values came from (attribute name => source), only known for files
+	Sources map[string]string `json:"sources,omitempty"`
+	// UsernameNote tells if the username was generated and not yet written to the people file
+	UsernameNote string                     `json:"username_note,omitempty"`
+	Attributes   map[string]attributeValues `json:"attributes,omitempty"`
+	Roles        []string                   `json:"roles,omitempty"`
+	Memberships  []*auditMembership         `json:"memberships"`
+	// Drift contains the disagreements between files and LDAP, only set for source both
+	Drift []string `json:"drift,omitempty"`
+}
+
+// auditMembership is a group a user is member of
+type auditMembership struct {
+	Group string `json:"group"`
+	Type  string `json:"type"`
+	// Via is the nested group, role or member rule the membership comes from; empty for direct memberships
+	Via        string `json:"via,omitempty"`
+	Until      string `json:"until,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Ticket     string `json:"ticket,omitempty"`
+	ApprovedBy string `json:"approved_by,omitempty"`
+	// Drift tells if the membership is missing in LDAP or not in files, only set for source both
+	Drift string `json:"drift,omitempty"`
+}
+
+// auditGroup is a groupOfNames or posixGroup with all users having access
+type auditGroup struct {
+	DN          string              `json:"dn"`
+	CN          string              `json:"cn"`
+	Type        string              `json:"type"`
+	Description string              `json:"description"`
+	Owners      []string            `json:"owners,omitempty"`
+	MemberCount int                 `json:"member_count"`
+	Members     []*auditGroupMember `json:"members"`
+	// Drift tells if the group is missing in LDAP or not in files, only set for source both
+	Drift string `json:"drift,omitempty"`
+}
+
+// auditGroupMember is a user having access to a group, the membership details are the ones of the user's membership
+type auditGroupMember struct {
+	Username string `json:"username"`
+	Via      string `json:"via,omitempty"`
+	Until    string `json:"until,omitempty"`
+	Drift    string `json:"drift,omitempty"`
+}
+
+// auditRole is a role with its groups and users
+type auditRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Groups      []string `json:"groups"`
+	Sudoers     []string `json:"sudoers,omitempty"`
+	Users       []string `json:"users"`
+}
+
+// auditExpiring is a membership expiring within the expiry warning window
+type auditExpiring struct {
+	Until  string `json:"until"`
+	Member string `json:"member"`
+	Group  string `json:"group"`
+}
+
+// buildAudit creates the audit report of the given source
+// LDAP must be loaded for sources ldap and both
+func buildAudit(source string) *auditReport {
+	var report *auditReport
+
+	switch source {
+	case "ldap":
+		report = buildLDAPAudit()
+	case "both":
+		report = mergeAudits(buildFileAudit(), buildLDAPAudit())
+	default:
+		report = buildFileAudit()
+	}
+
+	report.sort()
+
+	return report
+}
+
+// buildFileAudit creates the audit report of the config files
+func buildFileAudit() *auditReport {
+	var (
+		report     *auditReport
+		groups     groupIndex
+		unixGroups groupIndex
+		dn         string
+		groupDN    string
+		index      int
+		user       *posixAccount
+		current    *auditUser
+		membership groupMembership
+		entry      *groupMember
+		name       string
+		expiring   expiringMembership
+		roleInfo   *auditRole
+	)
+
+	report = &auditReport{
+		Source:            "file",
+		ExpiryWarningDays: *config.ExpiryWarningDays,
+	}
+
+	groups = newGroupIndex(localGroups)
+	unixGroups = newUnixGroupIndex(localUnixGroups)
+
+	for dn = range localPeople {
+		for index = range localPeople[dn].Objects {
+			user = &localPeople[dn].Objects[index]
+
+			current = &auditUser{
+				Username:     *user.UID,
+				DN:           user.dn,
+				PosixGroup:   localPeople[dn].CN,
+				GivenName:    *user.GivenName,
+				Surname:      *user.Surname,
+				DisplayName:  stringValue(user.DisplayName),
+				LoginShell:   stringValue(user.LoginShell),
+				Mail:         stringValue(user.Mail),
+				HomeDir:      stringValue(user.HomeDir),
+				UsernameNote: generatedNote(user),
+				Attributes:   user.Attributes,
+				Roles:        userRoles(user),
+				Sources: map[string]string{
+					"display_name":  valueSource(user, "display_name"),
+					"login_shell":   valueSource(user, "login_shell"),
+					"mail":          valueSource(user, "mail"),
+					"home_dir":      valueSource(user, "home_dir"),
+					"user_password": valueSource(user, "user_password"),
+				},
+			}
+
+			// memberships inherited through nested groups are shown with the group they come from, the ones granted by
+			// roles or member rules with the role or rule
+			for _, membership = range effectiveGroups(groups, user.dn) {
+				if membership.via == "" {
+					membership.via = localGroups[membership.dn].memberSources[user.dn]
+				}
+
+				entry = nil
+				if membership.via == "" {
+					entry = findGroupMember(localGroups[membership.dn].Members, *user.UID)
+				}
+
+				current.Memberships = append(current.Memberships, newAuditMembership(membership.dn, "groupOfNames",
+					membership.via, entry))
+			}
+
+			// every account is member of the posixGroup of its people file
+			current.Memberships = append(current.Memberships, newAuditMembership(dn, "posixGroup", "", nil))
+
+			for _, groupDN = range unixGroups[*user.UID] {
+				current.Memberships = append(current.Memberships, newAuditMembership(groupDN, "posixGroup", "",
+					findGroupMember(localUnixGroups[groupDN].members, *user.UID)))
+			}
+
+			report.Users = append(report.Users, current)
+		}
+	}
+
+	for dn = range localGroups {
+		report.Groups = append(report.Groups, &auditGroup{
+			DN:          dn,
+			CN:          localGroups[dn].CN,
+			Type:        "groupOfNames",
+			Description: localGroups[dn].Description,
+			Owners:      localGroups[dn].Owners,
+		})
+	}
+
+	for dn = range localUnixGroups {
+		report.Groups = append(report.Groups, &auditGroup{
+			DN:          dn,
+			CN:          localUnixGroups[dn].CN,
+			Type:        "posixGroup",
+			Description: localUnixGroups[dn].Description,
+		})
+	}
+
+	for dn = range localPeople {
+		report.Groups = append(report.Groups, &auditGroup{
+			DN:          dn,
+			CN:          localPeople[dn].CN,
+			Type:        "posixGroup",
+			Description: localPeople[dn].Description,
+		})
+	}
+
+	for _, name = range roleNames() {
+		roleInfo = &auditRole{
+			Name:        name,
+			Description: localRoles[name].Description,
+			Groups:      localRoles[name].groupDNs,
+		}
+
+		for index = range localRoles[name].Sudoers {
+			roleInfo.Sudoers = append(roleInfo.Sudoers, localRoles[name].Sudoers[index].describe())
+		}
+
+		report.Roles = append(report.Roles, roleInfo)
+	}
+
+	for _, expiring = range expiringMemberships() {
+		report.Expiring = append(report.Expiring, &auditExpiring{
+			Until:  formatTime(expiring.until),
+			Member: expiring.member,
+			Group:  expiring.group,
+		})
+	}
+
+	return report
+}
+
+// buildLDAPAudit creates the audit report of the objects existing in LDAP
+// roles and expiry of memberships only exist within files and are not part of it
+func buildLDAPAudit() *auditReport {
+	var (
+		report     *auditReport
+		groups     groupIndex
+		unixGroups groupIndex
+		dn         string
+		groupDN    string
+		index      int
+		user       *posixAccount
+		current    *auditUser
+		membership groupMembership
+	)
+
+	report = &auditReport{
+		Source:            "ldap",
+		ExpiryWarningDays: *config.ExpiryWarningDays,
+	}
+
+	groups = newGroupIndex(ldapGroups)
+	unixGroups = newUnixGroupIndex(ldapUnixGroups)
+
+	for dn = range ldapPeople {
+		for index = range ldapPeople[dn].Objects {
+			user = &ldapPeople[dn].Objects[index]
+
+			current = &auditUser{
+				Username:    stringValue(user.UID),
+				DN:          user.dn,
+				PosixGroup:  ldapPeople[dn].CN,
+				GivenName:   stringValue(user.GivenName),
+				Surname:     stringValue(user.Surname),
+				DisplayName: stringValue(user.DisplayName),
+				LoginShell:  stringValue(user.LoginShell),
+				Mail:        stringValue(user.Mail),
+				HomeDir:     stringValue(user.HomeDir),
+				Attributes:  user.Attributes,
+			}
+
+			for _, membership = range effectiveGroups(groups, user.dn) {
+				current.Memberships = append(current.Memberships, newAuditMembership(membership.dn, "groupOfNames",
+					membership.via, nil))
+			}
+
+			current.Memberships = append(current.Memberships, newAuditMembership(dn, "posixGroup", "", nil))
+
+			for _, groupDN = range unixGroups[current.Username] {
+				current.Memberships = append(current.Memberships, newAuditMembership(groupDN, "posixGroup", "", nil))
+			}
+
+			report.Users = append(report.Users, current)
+		}
+	}
+
+	for dn = range ldapGroups {
+		report.Groups = append(report.Groups, &auditGroup{
+			DN:          dn,
+			CN:          ldapGroups[dn].CN,
+			Type:        "groupOfNames",
+			Description: ldapGroups[dn].Description,
+			Owners:      dnUsernames(ldapGroups[dn].ownerDNs),
+		})
+	}
+
+	for dn = range ldapUnixGroups {
+		report.Groups = append(report.Groups, &auditGroup{
+			DN:          dn,
+			CN:          ldapUnixGroups[dn].CN,
+			Type:        "posixGroup",
+			Description: ldapUnixGroups[dn].Description,
+		})
+	}
+
+	for dn = range ldapPeople {
+		report.Groups = append(report.Groups, &auditGroup{
+			DN:          dn,
+			CN:          ldapPeople[dn].CN,
+			Type:        "posixGroup",
+			Description: ldapPeople[dn].Description,
+		})
+	}
+
+	return report
+}
+
+// mergeAudits adds everything only existing in LDAP to the report of the files and flags all disagreements
+// users, groups and memberships are matched by DN ignoring case
+func mergeAudits(file *auditReport, directory *auditReport) *auditReport {
+	var (
+		users      map[string]*auditUser
+		groups     map[string]*auditGroup
+		user       *auditUser
+		remote     *auditUser
+		group      *auditGroup
+		other      *auditGroup
+		membership *auditMembership
+		ok         bool
+	)
+
+	file.Source = "both"
+
+	users = make(map[string]*auditUser)
+	for _, user = range directory.Users {
+		users[strings.ToLower(user.DN)] = user
+	}
+
+	groups = make(map[string]*auditGroup)
+	for _, group = range directory.Groups {
+		groups[strings.ToLower(group.DN)] = group
+	}
+
+	for _, user = range file.Users {
+		if remote, ok = users[strings.ToLower(user.DN)]; !ok {
+			user.Drift = append(user.Drift, "missing in LDAP")
+
+			for _, membership = range user.Memberships {
+				membership.Drift = "missing in LDAP"
+			}
+
+			continue
+		}
+
+		delete(users, strings.ToLower(user.DN))
+
+		user.Drift = append(user.Drift, compareAuditUsers(user, remote)...)
+		user.Memberships = mergeAuditMemberships(user.Memberships, remote.Memberships)
+	}
+
+	// users only existing in LDAP are added with all their memberships
+	for _, user = range directory.Users {
+		if _, ok = users[strings.ToLower(user.DN)]; !ok {
+			continue
+		}
+
+		user.Drift = append(user.Drift, "not in files")
+
+		for _, membership = range user.Memberships {
+			membership.Drift = "not in files"
+		}
+
+		file.Users = append(file.Users, user)
+	}
+
+	for _, group = range file.Groups {
+		if other, ok = groups[strings.ToLower(group.DN)]; !ok {
+			group.Drift = "missing in LDAP"
+			continue
+		}
+
+		if !equalFold(group.Owners, other.Owners) {
+			group.Drift = fmt.Sprintf("owners: '%s' in files, '%s' in LDAP", strings.Join(group.Owners, ", "),
+				strings.Join(other.Owners, ", "))
+		}
+
+		delete(groups, strings.ToLower(group.DN))
+	}
+
+	for _, group = range directory.Groups {
+		if _, ok = groups[strings.ToLower(group.DN)]; ok {
+			group.Drift = "not in files"
+			file.Groups = append(file.Groups, group)
+		}
+	}
+
+	return file
+}
+
+// compareAuditUsers returns a description of every attribute with a different value in files and LDAP
+func compareAuditUsers(local *auditUser, remote *auditUser) []string {
+	var (
+		drift  []string
+		fields [][3]string
+		field  [3]string
+		name   string
+		names  []string
+	)
+
+	fields = [][3]string{
+		{"given_name", local.GivenName, remote.GivenName},
+		{"surname", local.Surname, remote.Surname},
+		{"display_name", local.DisplayName, remote.DisplayName},
+		{"login_shell", local.LoginShell, remote.LoginShell},
+		{"mail", local.Mail, remote.Mail},
+		{"home_dir", local.HomeDir, remote.HomeDir},
+	}
+
+	for _, field = range fields {
+		if field[1] != field[2] {
+			drift = append(drift, fmt.Sprintf("%s: '%s' in files, '%s' in LDAP", field[0], field[1], field[2]))
+		}
+	}
+
+	names = sortedAttributeNames(local.Attributes)
+	for _, name = range sortedAttributeNames(remote.Attributes) {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name = range names {
+		if !local.Attributes[name].equal(remote.Attributes[name]) {
+			drift = append(drift, fmt.Sprintf("%s: '%s' in files, '%s' in LDAP", name,
+				strings.Join(local.Attributes[name], ", "), strings.Join(remote.Attributes[name], ", ")))
+		}
+	}
+
+	return drift
+}
+
+// mergeAuditMemberships flags memberships of files missing in LDAP and adds the ones only existing in LDAP
+func mergeAuditMemberships(local []*auditMembership, remote []*auditMembership) []*auditMembership {
+	var (
+		groups     map[string]bool
+		membership *auditMembership
+	)
+
+	groups = make(map[string]bool)
+	for _, membership = range remote {
+		groups[strings.ToLower(membership.Group)] = true
+	}
+
+	for _, membership = range local {
+		if !groups[strings.ToLower(membership.Group)] {
+			membership.Drift = "missing in LDAP"
+		}
+
+		delete(groups, strings.ToLower(membership.Group))
+	}
+
+	for _, membership = range remote {
+		if groups[strings.ToLower(membership.Group)] {
+			membership.Drift = "not in files"
+			local = append(local, membership)
+		}
+	}
+
+	return local
+}
+
+// memberNames returns the usernames of all members of a group
+func (g *auditGroup) memberNames() []string {
+	var (
+		names  []string
+		member *auditGroupMember
+	)
+
+	for _, member = range g.Members {
+		names = append(names, member.Username)
+	}
+
+	return names
+}
+
+// dnUsernames returns the usernames of user DNs, the value of their first RDN
+func dnUsernames(dns []string) []string {
+	var (
+		names []string
+		dn    string
+		rdn   string
+	)
+
+	for _, dn = range dns {
+		rdn = strings.SplitN(dn, ",", 2)[0]
+		names = append(names, rdn[strings.Index(rdn, "=")+1:])
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// stringValue returns the value of an optional attribute or an empty string if it isn't set
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+// newAuditMembership creates a membership of the audit report including the details of its entry in the group file
+func newAuditMembership(group string, groupType string, via string, entry *groupMember) *auditMembership {
+	var membership *auditMembership
+
+	membership = &auditMembership{
+		Group: group,
+		Type:  groupType,
+		Via:   via,
+	}
+
+	if entry != nil {
+		if entry.Until != nil {
+			membership.Until = formatTime(*entry.Until)
+		}
+
+		membership.Reason = entry.Reason
+		membership.Ticket = entry.Ticket
+		membership.ApprovedBy = entry.ApprovedBy
+	}
+
+	return membership
+}
+
+// sort orders all lists of the report and fills the members of groups, the users of roles, empty groups and users
+// without memberships from the users' memberships and roles
+func (r *auditReport) sort() {
+	var (
+		groups     map[string]*auditGroup
+		roles      map[string]*auditRole
+		user       *auditUser
+		membership *auditMembership
+		group      *auditGroup
+		roleInfo   *auditRole
+		name       string
+	)
+
+	r.EmptyGroups = nil
+	r.UsersWithoutMemberships = nil
+
+	sort.Slice(r.Users, func(i, j int) bool {
+		if r.Users[i].PosixGroup == r.Users[j].PosixGroup {
+			return r.Users[i].Username < r.Users[j].Username
+		}
+		return r.Users[i].PosixGroup < r.Users[j].PosixGroup
+	})
+
+	sort.Slice(r.Groups, func(i, j int) bool {
+		return r.Groups[i].DN < r.Groups[j].DN
+	})
+
+	// groups are indexed by DN, so members are added while going through the users once
+	groups = make(map[string]*auditGroup)
+	for _, group = range r.Groups {
+		group.Members = nil
+		groups[strings.ToLower(group.DN)] = group
+	}
+
+	roles = make(map[string]*auditRole)
+	for _, roleInfo = range r.Roles {
+		roleInfo.Users = nil
+		roles[roleInfo.Name] = roleInfo
+	}
+
+	// users are sorted, so members and users of roles are sorted as well
+	for _, user = range r.Users {
+		sort.Slice(user.Memberships, func(i, j int) bool {
+			return user.Memberships[i].Group < user.Memberships[j].Group
+		})
+
+		if !hasGroupMemberships(user) {
+			r.UsersWithoutMemberships = append(r.UsersWithoutMemberships, user.Username)
+		}
+
+		for _, membership = range user.Memberships {
+			if group = groups[strings.ToLower(membership.Group)]; group != nil {
+				group.Members = append(group.Members, &auditGroupMember{
+					Username: user.Username,
+					Via:      membership.Via,
+					Until:    membership.Until,
+					Drift:    membership.Drift,
+				})
+			}
+		}
+
+		for _, name = range user.Roles {
+			if roles[name] != nil {
+				roles[name].Users = append(roles[name].Users, user.Username)
+			}
+		}
+	}
+
+	for _, group = range r.Groups {
+		group.MemberCount = len(group.Members)
+
+		if group.MemberCount == 0 {
+			r.EmptyGroups = append(r.EmptyGroups, group.DN)
+		}
+	}
+}
+
+// hasGroupMemberships returns true if the user is member of any group besides the posixGroup of its people file,
+// which every account is member of
+func hasGroupMemberships(user *auditUser) bool {
+	var (
+		parts      []string
+		membership *auditMembership
+	)
+
+	parts = strings.SplitN(user.DN, ",", 2)
+
+	for _, membership = range user.Memberships {
+		if len(parts) < 2 || !strings.EqualFold(membership.Group, parts[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeAuditText writes the audit report as human readable text
+func writeAuditText(w io.Writer, r *auditReport) {
+	var (
+		user       *auditUser
+		membership *auditMembership
+		posixGroup string
+		group      *auditGroup
+		groupDrift bool
+		roleInfo   *auditRole
+		expiring   *auditExpiring
+		name       string
+	)
+
+	fmt.Fprintf(w, "\n\n====== START AUDIT ======")
+
+	// users are sorted by their posixGroup
+	posixGroup = "\x00"
+
+	for _, user = range r.Users {
+		if user.PosixGroup != posixGroup {
+			posixGroup = user.PosixGroup
+			fmt.Fprintf(w, "\n  === %s ===\n\n", posixGroup)
+		}
+
+		fmt.Fprintf(w, "    -------\n")
+
+		fmt.Fprintf(w, "    Username: %s%s\n    Given Name: %s\n    Last Name: %s\n",
+			user.Username,
+			user.UsernameNote,
+			user.GivenName,
+			user.Surname)
+
+		fmt.Fprintf(w, "    Display Name: %s%s\n    Login Shell: %s%s\n    Mail: %s%s\n    Home Dir: %s%s\n",
+			user.DisplayName, sourceNote(user, "display_name"),
+			user.LoginShell, sourceNote(user, "login_shell"),
+			user.Mail, sourceNote(user, "mail"),
+			user.HomeDir, sourceNote(user, "home_dir"))
+
+		fmt.Fprintf(w, "    User Password: ********%s\n", sourceNote(user, "user_password"))
+
+		if len(user.Attributes) > 0 {
+			fmt.Fprintf(w, "    Attributes:\n")
+
+			for _, name = range sortedAttributeNames(user.Attributes) {
+				fmt.Fprintf(w, "      %s: %s\n", name, strings.Join(user.Attributes[name], ", "))
+			}
+		}
+
+		if len(user.Roles) > 0 {
+			fmt.Fprintf(w, "    Roles: %s\n", strings.Join(user.Roles, ", "))
+		}
+
+		if len(user.Drift) > 0 {
+			fmt.Fprintf(w, "    Drift:\n")
+
+			for _, name = range user.Drift {
+				fmt.Fprintf(w, "      !! %s\n", name)
+			}
+		}
+
+		fmt.Fprintf(w, "    Memberships:\n")
+
+		for _, membership = range user.Memberships {
+			fmt.Fprintf(w, "      %s%s\n", membership.Group, membership.note())
+
+			if membership.metadata() != "" {
+				fmt.Fprintf(w, "        %s\n", membership.metadata())
+			}
+		}
+
+		fmt.Fprintf(w, "    -------\n")
+	}
+
+	for _, group = range r.Groups {
+		if group.Drift == "" {
+			continue
+		}
+
+		if !groupDrift {
+			groupDrift = true
+			fmt.Fprintf(w, "\n  === Group Drift ===\n\n")
+		}
+
+		fmt.Fprintf(w, "    %s (%s, !! %s)\n", group.DN, group.Type, group.Drift)
+	}
+
+	if len(r.Roles) > 0 {
+		fmt.Fprintf(w, "\n  === Roles ===\n\n")
+	}
+
+	for _, roleInfo = range r.Roles {
+		fmt.Fprintf(w, "    -------\n    Role: %s\n    Description: %s\n    Groups:\n", roleInfo.Name, roleInfo.Description)
+
+		for _, name = range roleInfo.Groups {
+			fmt.Fprintf(w, "      %s\n", name)
+		}
+
+		if len(roleInfo.Sudoers) > 0 {
+			fmt.Fprintf(w, "    Sudoers:\n")
+
+			for _, name = range roleInfo.Sudoers {
+				fmt.Fprintf(w, "      %s\n", name)
+			}
+		}
+
+		fmt.Fprintf(w, "    Users:\n")
+
+		for _, name = range roleInfo.Users {
+			fmt.Fprintf(w, "      %s\n", name)
+		}
+
+		fmt.Fprintf(w, "    -------\n")
+	}
+
+	if len(r.Expiring) > 0 {
+		fmt.Fprintf(w, "\n  === Memberships expiring within %d days ===\n\n", r.ExpiryWarningDays)
+	}
+
+	for _, expiring = range r.Expiring {
+		fmt.Fprintf(w, "    %s  %s in %s\n", expiring.Until, expiring.Member, expiring.Group)
+	}
+
+	fmt.Fprintf(w, "====== END AUDIT ======\n")
+}
+
+// writeAuditGroupsText writes the group-centric view of the audit report as human readable text
+func writeAuditGroupsText(w io.Writer, r *auditReport) {
+	var (
+		group  *auditGroup
+		member *auditGroupMember
+		name   string
+	)
+
+	fmt.Fprintf(w, "\n\n====== START AUDIT ======")
+	fmt.Fprintf(w, "\n  === Groups ===\n\n")
+
+	for _, group = range r.Groups {
+		if group.MemberCount == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "    -------\n    Group: %s\n    Type: %s\n    Description: %s\n", group.DN, group.Type,
+			group.Description)
+
+		if len(group.Owners) > 0 {
+			fmt.Fprintf(w, "    Owners: %s\n", strings.Join(group.Owners, ", "))
+		}
+
+		if group.Drift != "" {
+			fmt.Fprintf(w, "    Drift:\n      !! %s\n", group.Drift)
+		}
+
+		fmt.Fprintf(w, "    Members (%d):\n", group.MemberCount)
+
+		for _, member = range group.Members {
+			fmt.Fprintf(w, "      %s%s\n", member.Username, member.note())
+		}
+
+		fmt.Fprintf(w, "    -------\n")
+	}
+
+	if len(r.EmptyGroups) > 0 {
+		fmt.Fprintf(w, "\n  === Empty Groups ===\n\n")
+	}
+
+	for _, group = range r.Groups {
+		if group.MemberCount == 0 {
+			fmt.Fprintf(w, "    %s%s\n", group.DN, membershipNote([]string{group.Type}, "", "", group.Drift))
+		}
+	}
+
+	if len(r.UsersWithoutMemberships) > 0 {
+		fmt.Fprintf(w, "\n  === Users without Memberships ===\n\n")
+	}
+
+	for _, name = range r.UsersWithoutMemberships {
+		fmt.Fprintf(w, "    %s\n", name)
+	}
+
+	fmt.Fprintf(w, "====== END AUDIT ======\n")
+}
+
+// note returns type, origin and expiry of a membership for text output
+func (m *auditMembership) note() string {
+	var notes []string
+
+	if m.Type == "posixGroup" {
+		notes = append(notes, "posixGroup")
+	}
+
+	return membershipNote(notes, m.Via, m.Until, m.Drift)
+}
+
+// note returns origin and expiry of a group member for text output
+func (m *auditGroupMember) note() string {
+	return membershipNote(nil, m.Via, m.Until, m.Drift)
+}
+
+// membershipNote appends origin, expiry and drift of a membership to notes and returns them in parentheses
+func membershipNote(notes []string, via string, until string, drift string) string {
+	if via != "" {
+		notes = append(notes, "via "+via)
+	}
+
+	if until != "" {
+		notes = append(notes, "until "+until)
+	}
+
+	if drift != "" {
+		notes = append(notes, "!! "+drift)
+	}
+
+	if len(notes) == 0 {
+		return ""
+	}
+
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// metadata returns reason, ticket and approver of a membership for text output
+func (m *auditMembership) metadata() string {
+	var parts []string
+
+	if m.Reason != "" {
+		parts = append(parts, "reason: "+m.Reason)
+	}
+
+	if m.Ticket != "" {
+		parts = append(parts, "ticket: "+m.Ticket)
+	}
+
+	if m.ApprovedBy != "" {
+		parts = append(parts, "approved_by: "+m.ApprovedBy)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// sourceNote returns where the value of an attribute came from for text output
+func sourceNote(user *auditUser, name string) string {
+	if user.Sources[name] == "" {
+		return ""
+	}
+
+	return " (" + user.Sources[name] + ")"
+}
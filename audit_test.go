@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+// TestAuditPeopleGroups verifies the posixGroups of people files are part of the audit with all of their accounts
+// without counting as a membership of users that aren't member of any other group
+func TestAuditPeopleGroups(t *testing.T) {
+	var (
+		devopsDN  = "cn=devops,ou=people,dc=my-domain,dc=com"
+		emptyDN   = "cn=contractors,ou=people,dc=my-domain,dc=com"
+		adminDN   = "cn=ldap-admin,ou=groups,dc=my-domain,dc=com"
+		uid       = "johndoe"
+		admin     = "janedoe"
+		value     = "value"
+		days      = 14
+		report    *auditReport
+		group     *auditGroup
+		found     bool
+		groupType string
+	)
+
+	config = &configuration{ExpiryWarningDays: &days}
+	localPeople = map[string]posixGroup{
+		devopsDN: posixGroup{
+			dn: devopsDN,
+			CN: "devops",
+			Objects: []posixAccount{
+				{
+					dn:          "uid=johndoe," + devopsDN,
+					UID:         &uid,
+					GivenName:   &value,
+					Surname:     &value,
+					DisplayName: &value,
+					LoginShell:  &value,
+					Mail:        &value,
+					HomeDir:     &value,
+				},
+				{
+					dn:          "uid=janedoe," + devopsDN,
+					UID:         &admin,
+					GivenName:   &value,
+					Surname:     &value,
+					DisplayName: &value,
+					LoginShell:  &value,
+					Mail:        &value,
+					HomeDir:     &value,
+				},
+			},
+		},
+		emptyDN: posixGroup{dn: emptyDN, CN: "contractors"},
+	}
+	localGroups = map[string]groupOfNames{
+		adminDN: groupOfNames{dn: adminDN, CN: "ldap-admin", memberDNs: []string{"uid=janedoe," + devopsDN}},
+	}
+	localUnixGroups = map[string]posixGroup{}
+	localRoles = map[string]role{}
+	defer func() {
+		config = nil
+		localPeople = nil
+		localGroups = nil
+		localUnixGroups = nil
+		localRoles = nil
+	}()
+
+	report = buildAudit("file")
+
+	for _, group = range report.Groups {
+		if group.DN != devopsDN {
+			continue
+		}
+
+		found = true
+		groupType = group.Type
+
+		if group.MemberCount != 2 {
+			t.Errorf("expected %s and %s as members of %s, got %d members", admin, uid, devopsDN, group.MemberCount)
+		}
+	}
+
+	if !found || groupType != "posixGroup" {
+		t.Errorf("posixGroup %s of people file missing in audit groups", devopsDN)
+	}
+
+	if len(report.EmptyGroups) != 1 || report.EmptyGroups[0] != emptyDN {
+		t.Errorf("expected %s as only empty group, got %v", emptyDN, report.EmptyGroups)
+	}
+
+	// johndoe is only member of the posixGroup of the people file
+	if len(report.UsersWithoutMemberships) != 1 || report.UsersWithoutMemberships[0] != uid {
+		t.Errorf("expected %s as only user without memberships, got %v", uid, report.UsersWithoutMemberships)
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/kpango/glg"
 )
@@ -86,6 +88,8 @@ func comparePosixGroups() error {
 		err            error
 		groupIsMissing bool
 		missmatch      bool
+		uids           []string
+		missing        []string
 	)
 
 	glg.Info("comparing posixGroups")
@@ -147,6 +151,18 @@ func comparePosixGroups() error {
 		for userIndex = range localPeople[dn].Objects {
 			foundUser = false
 
+			// accounts are provisioned on their start date, diff lists them as pending until then; accounts that
+			// already exist are left as they are
+			if localPeople[dn].Objects[userIndex].pending(now) {
+				if !groupIsMissing && ldapPeople[dn].findObject(*localPeople[dn].Objects[userIndex].UID) != nil {
+					glg.Warnf("posixAccount %s already exists in LDAP but %s, it is left untouched until then",
+						localPeople[dn].Objects[userIndex].dn, localPeople[dn].Objects[userIndex].pendingReason(now))
+				}
+
+				glg.Debugf("skipping pending posixAccount %s", localPeople[dn].Objects[userIndex].dn)
+				continue
+			}
+
 			// only check users when group exists; if it is missing, foundUser must be false so users get added to the group
 			// that will be created in the same sync cycle
 			if !groupIsMissing {
@@ -163,6 +179,12 @@ func comparePosixGroups() error {
 			}
 
 			if !foundUser {
+				if missing = localPeople[dn].Objects[userIndex].missingAttributes(); missing != nil {
+					return fmt.Errorf("%s:%d: can't create %s, %s not set in object and no default is defined",
+						localPeople[dn].Objects[userIndex].file, localPeople[dn].Objects[userIndex].node.Line,
+						localPeople[dn].Objects[userIndex].dn, strings.Join(missing, ", "))
+				}
+
 				glg.Debugf("marked posixAccount for creation %s", localPeople[dn].Objects[userIndex].dn)
 
 				// create new task
@@ -171,9 +193,18 @@ func comparePosixGroups() error {
 				task.objectType = objectTypePosixAccount
 				task.taskType = taskTypeCreate
 				task.data = &localPeople[dn].Objects[userIndex]
+				task.note = localPeople[dn].Objects[userIndex].disabledReason(now)
 				taskList = append(taskList, task)
 			}
 		}
+
+		// all users of a people file are members (memberUid) of its posixGroup
+		uids = nil
+		for userIndex = range localPeople[dn].Objects {
+			uids = append(uids, *localPeople[dn].Objects[userIndex].UID)
+		}
+
+		compareMemberUIDs(dn, uids, ldapPeople[dn].memberUIDs, nil)
 	}
 
 	// go through all user objects in LDAP and find objects that only exist in LDAP and therefore need to be deleted
@@ -226,6 +257,7 @@ func comparePosixAccount(local *posixAccount, remote *posixAccount) error {
 		// userDiff contains only those values that need to be changed and their new values
 		userDiff *posixAccount
 		mismatch bool
+		password string
 	)
 
 	if *local.UID != *remote.UID {
@@ -236,32 +268,32 @@ func comparePosixAccount(local *posixAccount, remote *posixAccount) error {
 	userDiff = new(posixAccount)
 	userDiff.dn = local.dn
 
-	if *local.GivenName != *remote.GivenName {
+	if local.managed("given_name") && stringChanged(local.GivenName, remote.GivenName) {
 		mismatch = true
 		userDiff.GivenName = local.GivenName
 	}
 
-	if *local.Surname != *remote.Surname {
+	if local.managed("surname") && stringChanged(local.Surname, remote.Surname) {
 		mismatch = true
 		userDiff.Surname = local.Surname
 	}
 
-	if *local.DisplayName != *remote.DisplayName {
+	if local.managed("display_name") && stringChanged(local.DisplayName, remote.DisplayName) {
 		mismatch = true
 		userDiff.DisplayName = local.DisplayName
 	}
 
-	if *local.LoginShell != *remote.LoginShell {
+	if local.managed("login_shell") && stringChanged(local.LoginShell, remote.LoginShell) {
 		mismatch = true
 		userDiff.LoginShell = local.LoginShell
 	}
 
-	if *local.Mail != *remote.Mail {
+	if local.managed("mail") && stringChanged(local.Mail, remote.Mail) {
 		mismatch = true
 		userDiff.Mail = local.Mail
 	}
 
-	if *config.EnableSSHPublicKeys {
+	if *config.EnableSSHPublicKeys && local.managed("ssh_public_key") {
 		// SSHPublicKey can be nil
 		switch {
 
@@ -285,14 +317,54 @@ func comparePosixAccount(local *posixAccount, remote *posixAccount) error {
 		}
 	}
 
-	if *local.HomeDir != *remote.HomeDir {
+	if local.managed("home_dir") && stringChanged(local.HomeDir, remote.HomeDir) {
 		mismatch = true
 		userDiff.HomeDir = local.HomeDir
 	}
 
-	if *local.UserPassword != *remote.UserPassword {
+	// disabled and expired accounts keep their password hash behind lockedPasswordPrefix
+	switch {
+	case local.managed("user_password"):
+		password = ldapPassword(local)
+
+	case remote.UserPassword != nil:
+		// passwords that aren't managed stay as they are in LDAP and are only locked or unlocked
+		password = strings.TrimPrefix(*remote.UserPassword, lockedPasswordPrefix)
+		if local.isDisabled(now) {
+			password = lockedPasswordPrefix + password
+		}
+	}
+
+	if password != "" && (remote.UserPassword == nil || password != *remote.UserPassword) {
+		mismatch = true
+		userDiff.UserPassword = &password
+
+		if local.isDisabled(now) != (remote.Disabled != nil && *remote.Disabled) {
+			userDiff.Disabled = new(bool)
+			*userDiff.Disabled = local.isDisabled(now)
+		}
+	}
+
+	if !sameExpiry(local.Expires, remote.Expires) {
+		mismatch = true
+		userDiff.Expires = local.Expires
+
+		// to tell that it is to be deleted, set zero time
+		if local.Expires == nil {
+			userDiff.Expires = new(time.Time)
+		}
+	}
+
+	if local.isLocked() != remote.isLocked() {
+		mismatch = true
+		userDiff.Locked = new(bool)
+		*userDiff.Locked = local.isLocked()
+	}
+
+	userDiff.Attributes = local.managedAttributes(compareAttributes(local.Attributes, remote.Attributes,
+		objectTypePosixAccount))
+	if userDiff.Attributes != nil {
 		mismatch = true
-		userDiff.UserPassword = local.UserPassword
 	}
 
 	if mismatch {
@@ -302,7 +374,12 @@ func comparePosixAccount(local *posixAccount, remote *posixAccount) error {
 		task.dn = local.dn
 		task.objectType = objectTypePosixAccount
 		task.taskType = taskTypeUpdate
+		// ldapUpdatePosixAccount checks the policy again before changing attributes
+		userDiff.AttributePolicy = local.AttributePolicy
 		task.data = userDiff
+		if userDiff.Disabled != nil && *userDiff.Disabled {
+			task.note = local.disabledReason(now)
+		}
 		taskList = append(taskList, task)
 	}
 
@@ -316,10 +393,10 @@ func compareGroupOfNames() error {
 		ok        bool
 		index     int
 		ldapIndex int
-		match     bool
 		task      *actionTask
-		dn2       string
-		index2    int
+		group     *groupOfNames
+		local     groupOfNames
+		mismatch  bool
 	)
 
 	glg.Info("comparing groupOfNames")
@@ -339,8 +416,46 @@ func compareGroupOfNames() error {
 			taskList = append(taskList, task)
 		}
 
+		group = new(groupOfNames)
+		group.dn = dn
+		mismatch = false
+
 		// TODO: check for missing value
 		if ldapGroups[dn].Description != localGroups[dn].Description {
+			mismatch = true
+			group.Description = localGroups[dn].Description
+		}
+
+		// new groups are created with all attributes
+		if ok {
+			// gid_number turns a groupOfNames into a posixGroup as well
+			switch {
+			case localGroups[dn].GIDNumber != nil && ldapGroups[dn].GIDNumber == nil:
+				mismatch = true
+				group.GIDNumber = localGroups[dn].GIDNumber
+				group.addPosixGroup = true
+
+			case localGroups[dn].GIDNumber == nil && ldapGroups[dn].GIDNumber != nil:
+				mismatch = true
+				group.deletePosixGroup = true
+
+			case localGroups[dn].GIDNumber != nil && *localGroups[dn].GIDNumber != *ldapGroups[dn].GIDNumber:
+				mismatch = true
+				group.GIDNumber = localGroups[dn].GIDNumber
+			}
+
+			if group.Attributes = compareAttributes(localGroups[dn].Attributes, ldapGroups[dn].Attributes, objectTypeGroupOfNames); group.Attributes != nil {
+				mismatch = true
+			}
+
+			if !equalFold(localGroups[dn].ownerDNs, ldapGroups[dn].ownerDNs) {
+				mismatch = true
+				group.updateOwners = true
+				group.ownerDNs = localGroups[dn].ownerDNs
+			}
+		}
+
+		if mismatch {
 			glg.Debugf("marked groupOfNames for update %s", dn)
 
 			// add task to update group
@@ -348,39 +463,21 @@ func compareGroupOfNames() error {
 			task.dn = dn
 			task.objectType = objectTypeGroupOfNames
 			task.taskType = taskTypeUpdate
-			task.data = new(groupOfNames)
-			task.data.(*groupOfNames).Description = localGroups[dn].Description
-			task.data.(*groupOfNames).dn = dn
+			task.data = group
 			taskList = append(taskList, task)
 		}
 
-		for index = range localGroups[dn].Members {
-			match = false
-			for ldapIndex = range ldapGroups[dn].Members {
-				if localGroups[dn].Members[index] == ldapGroups[dn].Members[ldapIndex] {
-					match = true
-				}
-			}
-
-			if !match {
-			out1:
-				// get user DN
-				for dn2 = range localPeople {
-					for index2 = range localPeople[dn2].Objects {
-						if *localPeople[dn2].Objects[index2].UID == localGroups[dn].Members[index] {
-							task = new(actionTask)
-							task.data = localPeople[dn2].Objects[index2].dn
-							// fast leaving of loops
-							break out1
-						}
-					}
-				}
-
-				glg.Debugf("marked member for creation %s", localPeople[dn2].Objects[index2].dn)
+		for index = range localGroups[dn].memberDNs {
+			if !containsFold(ldapGroups[dn].memberDNs, localGroups[dn].memberDNs[index]) &&
+				!pendingMember(localGroups[dn].memberDNs[index]) {
+				glg.Debugf("marked member for creation %s", localGroups[dn].memberDNs[index])
 
+				task = new(actionTask)
 				task.dn = dn
 				task.objectType = objectTypeGroupOfNames
 				task.taskType = taskTypeAddMember
+				task.data = localGroups[dn].memberDNs[index]
+				task.note = localGroups[dn].memberSources[localGroups[dn].memberDNs[index]]
 				taskList = append(taskList, task)
 			}
 		}
@@ -400,47 +497,157 @@ func compareGroupOfNames() error {
 			continue
 		}
 
-		for ldapIndex = range ldapGroups[dn].Members {
+		local = localGroups[dn]
 
-			// ignore dummy member
-			if ldapGroups[dn].Members[ldapIndex] == "uid=MonbanDummyMember" {
-				continue
+		for ldapIndex = range ldapGroups[dn].memberDNs {
+			if !containsFold(localGroups[dn].memberDNs, ldapGroups[dn].memberDNs[ldapIndex]) {
+				glg.Debugf("marked member for deletion %s", ldapGroups[dn].memberDNs[ldapIndex])
+
+				task = new(actionTask)
+				task.dn = dn
+				task.objectType = objectTypeGroupOfNames
+				task.taskType = taskTypeDeleteMember
+				task.data = ldapGroups[dn].memberDNs[ldapIndex]
+				task.note = memberRemovalNote(&local, ldapGroups[dn].memberDNs[ldapIndex])
+				taskList = append(taskList, task)
 			}
+		}
+	}
 
-			match = false
-			for index = range localGroups[dn].Members {
-				if ldapGroups[dn].Members[ldapIndex] == localGroups[dn].Members[index] {
-					match = true
-				}
+	glg.Info("finished comparing groupOfNames")
+
+	return nil
+}
+
+// compareUnixGroups compares local and ldap posixGroups defined in group_dir including their memberUid members
+func compareUnixGroups() error {
+	var (
+		dn             string
+		ok             bool
+		task           *actionTask
+		group          *posixGroup
+		groupIsMissing bool
+		mismatch       bool
+	)
+
+	glg.Info("comparing unix posixGroups")
+
+	for dn = range localUnixGroups {
+		groupIsMissing = false
+		mismatch = false
+
+		if _, ok = ldapUnixGroups[dn]; !ok {
+			groupIsMissing = true
+
+			glg.Debugf("marked posixGroup for creation %s", dn)
+
+			task = new(actionTask)
+			task.dn = dn
+			task.objectType = objectTypePosixGroup
+			task.taskType = taskTypeCreate
+			task.data = localUnixGroups[dn]
+			taskList = append(taskList, task)
+		}
+
+		if !groupIsMissing {
+			group = new(posixGroup)
+			group.dn = dn
+
+			if ldapUnixGroups[dn].GIDNumber == nil || *localUnixGroups[dn].GIDNumber != *ldapUnixGroups[dn].GIDNumber {
+				mismatch = true
+				group.GIDNumber = localUnixGroups[dn].GIDNumber
 			}
 
-			if !match {
-				// user needs to be deleted from group
-
-			out2:
-				// get user DN from ldap
-				for dn2 = range ldapPeople {
-					for index = range ldapPeople[dn2].Objects {
-						if ldapGroups[dn].Members[ldapIndex] == *ldapPeople[dn2].Objects[index].UID {
-							task = new(actionTask)
-							task.data = ldapPeople[dn2].Objects[index].dn
-							// fast leaving of loops
-							break out2
-						}
-					}
-				}
+			if localUnixGroups[dn].Description != ldapUnixGroups[dn].Description {
+				mismatch = true
+				group.Description = localUnixGroups[dn].Description
+			}
 
-				glg.Debugf("marked member for deletion %s", ldapPeople[dn2].Objects[index].dn)
+			if mismatch {
+				glg.Debugf("marked posixGroup for update %s", dn)
 
+				task = new(actionTask)
 				task.dn = dn
-				task.objectType = objectTypeGroupOfNames
-				task.taskType = taskTypeDeleteMember
+				task.objectType = objectTypePosixGroup
+				task.taskType = taskTypeUpdate
+				task.data = group
 				taskList = append(taskList, task)
 			}
 		}
+
+		// members of groups that are created in the same sync cycle are all new
+		compareMemberUIDs(dn, localUnixGroups[dn].memberUIDs, ldapUnixGroups[dn].memberUIDs, localUnixGroups[dn].members)
 	}
 
-	glg.Info("finished comparing groupOfNames")
+	for dn = range ldapUnixGroups {
+		if _, ok = localUnixGroups[dn]; !ok {
+			glg.Debugf("marked posixGroup for deletion %s", dn)
+
+			task = new(actionTask)
+			task.dn = dn
+			task.objectType = objectTypePosixGroup
+			task.taskType = taskTypeDelete
+			taskList = append(taskList, task)
+		}
+	}
+
+	glg.Info("finished comparing unix posixGroups")
 
 	return nil
 }
+
+// compareMemberUIDs creates tasks to add and delete memberUid values of a posixGroup so LDAP matches local
+// entries are the member entries of the group file if there is one, they explain why members are removed
+func compareMemberUIDs(dn string, local []string, remote []string, entries []groupMember) {
+	var (
+		uid   string
+		task  *actionTask
+		entry *groupMember
+		user  *posixAccount
+	)
+
+	for _, uid = range local {
+		// accounts that haven't started yet become members on their start date, existing memberships are kept
+		if user = findUser(uid); user != nil && user.pending(now) {
+			continue
+		}
+
+		if !containsString(remote, uid) {
+			glg.Debugf("marked posixGroup member %s for creation in %s", uid, dn)
+
+			task = new(actionTask)
+			task.dn = dn
+			task.objectType = objectTypePosixGroup
+			task.taskType = taskTypeAddMember
+			task.data = uid
+			taskList = append(taskList, task)
+		}
+	}
+
+	for _, uid = range remote {
+		if !containsString(local, uid) {
+			glg.Debugf("marked posixGroup member %s for deletion in %s", uid, dn)
+
+			task = new(actionTask)
+			task.dn = dn
+			task.objectType = objectTypePosixGroup
+			task.taskType = taskTypeDeleteMember
+			task.data = uid
+
+			if entry = findGroupMember(entries, uid); entry != nil {
+				task.note = entry.inactiveReason(now)
+			}
+
+			taskList = append(taskList, task)
+		}
+	}
+}
+
+// stringChanged returns true if two optional values differ, a value missing on one side only counts as difference
+func stringChanged(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+
+	return *a != *b
+}
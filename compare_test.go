@@ -0,0 +1,213 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testAccount returns an account with all mandatory attributes set
+func testAccount(uid string) posixAccount {
+	var (
+		value    = "value"
+		password = "{SSHA}secret"
+	)
+
+	return posixAccount{
+		dn:           "uid=" + uid + ",cn=devops,ou=people,dc=my-domain,dc=com",
+		UID:          &uid,
+		GivenName:    &value,
+		Surname:      &value,
+		DisplayName:  &value,
+		LoginShell:   &value,
+		Mail:         &value,
+		HomeDir:      &value,
+		UserPassword: &password,
+	}
+}
+
+// setupCompare resets all state used by the compare functions and returns a function restoring it
+func setupCompare(t time.Time) func() {
+	var disabled bool
+
+	config = &configuration{EnableSSHPublicKeys: &disabled}
+	now = t
+	taskList = nil
+
+	return func() {
+		config = nil
+		now = time.Time{}
+		taskList = nil
+	}
+}
+
+// TestComparePosixAccountState verifies disabled, expired and locked accounts are updated in LDAP
+func TestComparePosixAccountState(t *testing.T) {
+	var (
+		today    = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		past     = today.AddDate(0, 0, -1)
+		future   = today.AddDate(0, 1, 0)
+		enabled  = true
+		local    posixAccount
+		remote   posixAccount
+		diff     *posixAccount
+		expected string
+	)
+
+	defer setupCompare(today)()
+
+	// an active account without changes
+	local = testAccount("johndoe")
+	remote = testAccount("johndoe")
+	if comparePosixAccount(&local, &remote); len(taskList) != 0 {
+		t.Fatalf("expected no task for unchanged account, got %d", len(taskList))
+	}
+
+	// disabled accounts keep their hash behind lockedPasswordPrefix
+	local.Disabled = &enabled
+	if comparePosixAccount(&local, &remote); len(taskList) != 1 {
+		t.Fatalf("expected update task for disabled account, got %d tasks", len(taskList))
+	}
+
+	diff = taskList[0].data.(*posixAccount)
+	expected = lockedPasswordPrefix + *remote.UserPassword
+	if diff.UserPassword == nil || *diff.UserPassword != expected || diff.Disabled == nil || !*diff.Disabled {
+		t.Errorf("expected password %s of disabled account, got %v", expected, diff.UserPassword)
+	}
+
+	// the disabled account as loaded from LDAP doesn't change anymore
+	taskList = nil
+	remote.UserPassword = &expected
+	remote.Disabled = &enabled
+	if comparePosixAccount(&local, &remote); len(taskList) != 0 {
+		t.Errorf("expected no task for disabled account already disabled in LDAP, got %d", len(taskList))
+	}
+
+	// accounts past their expiry date are disabled as well
+	taskList = nil
+	local = testAccount("johndoe")
+	local.Expires = &past
+	remote = testAccount("johndoe")
+	if comparePosixAccount(&local, &remote); len(taskList) != 1 {
+		t.Fatalf("expected update task for expired account, got %d tasks", len(taskList))
+	}
+
+	diff = taskList[0].data.(*posixAccount)
+	if diff.Disabled == nil || !*diff.Disabled || diff.Expires == nil || !diff.Expires.Equal(past) {
+		t.Errorf("expected expired account to be disabled with shadowExpire set")
+	}
+
+	// expiry dates are removed by zero time
+	taskList = nil
+	local = testAccount("johndoe")
+	remote = testAccount("johndoe")
+	remote.Expires = &future
+	if comparePosixAccount(&local, &remote); len(taskList) != 1 {
+		t.Fatalf("expected update task for removed expiry date, got %d tasks", len(taskList))
+	}
+
+	diff = taskList[0].data.(*posixAccount)
+	if diff.Expires == nil || !diff.Expires.IsZero() || diff.UserPassword != nil {
+		t.Errorf("expected removal of shadowExpire only")
+	}
+
+	// permanent locks
+	taskList = nil
+	local = testAccount("johndoe")
+	local.Locked = &enabled
+	remote = testAccount("johndoe")
+	if comparePosixAccount(&local, &remote); len(taskList) != 1 {
+		t.Fatalf("expected update task for locked account, got %d tasks", len(taskList))
+	}
+
+	diff = taskList[0].data.(*posixAccount)
+	if diff.Locked == nil || !*diff.Locked {
+		t.Errorf("expected account to be locked")
+	}
+}
+
+// TestShadowExpire verifies dates are converted to days since 1970-01-01 and back
+func TestShadowExpire(t *testing.T) {
+	var (
+		date   = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		value  string
+		parsed *time.Time
+	)
+
+	if value = shadowExpireValue(date); value != "20745" {
+		t.Errorf("expected shadowExpire 20745 for %s, got %s", formatTime(date), value)
+	}
+
+	if parsed = parseShadowExpire("20745"); parsed == nil || !parsed.Equal(date) {
+		t.Errorf("expected %s for shadowExpire 20745, got %v", formatTime(date), parsed)
+	}
+
+	if parsed = parseShadowExpire("-1"); parsed != nil {
+		t.Errorf("expected no date for shadowExpire -1, got %v", parsed)
+	}
+}
+
+// TestComparePosixGroupsPending verifies accounts are created on their start date while existing accounts with a
+// start date in the future are neither changed nor deleted
+func TestComparePosixGroupsPending(t *testing.T) {
+	var (
+		dn       = "cn=devops,ou=people,dc=my-domain,dc=com"
+		today    = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		start    = today.AddDate(0, 0, 7)
+		gid      = 1001
+		existing posixAccount
+		newHire  posixAccount
+		changed  posixAccount
+		task     *actionTask
+		err      error
+	)
+
+	defer setupCompare(today)()
+
+	existing = testAccount("johndoe")
+	existing.StartDate = &start
+	newHire = testAccount("janedoe")
+	newHire.StartDate = &start
+
+	// the existing account differs in LDAP, which is ignored until the start date
+	changed = testAccount("johndoe")
+	changed.Mail = new(string)
+
+	localPeople = map[string]posixGroup{
+		dn: posixGroup{dn: dn, GIDNumber: &gid, Objects: []posixAccount{existing, newHire}},
+	}
+	ldapPeople = map[string]posixGroup{
+		dn: posixGroup{dn: dn, GIDNumber: &gid, Objects: []posixAccount{changed}, memberUIDs: []string{"johndoe"}},
+	}
+	defer func() {
+		localPeople = nil
+		ldapPeople = nil
+	}()
+
+	if err = comparePosixGroups(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, task = range taskList {
+		t.Errorf("expected no tasks for pending accounts, got task type %d for %s", task.taskType, task.dn)
+	}
+
+	// on the start date the new hire is created and becomes member of the people group
+	now = start
+	if err = comparePosixGroups(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, task = range taskList {
+		switch {
+		case task.taskType == taskTypeCreate && task.dn == newHire.dn:
+		case task.taskType == taskTypeAddMember && task.data == "janedoe":
+		case task.taskType == taskTypeUpdate && task.dn == existing.dn:
+		default:
+			t.Errorf("unexpected task type %d for %s", task.taskType, task.dn)
+		}
+	}
+
+	if len(taskList) != 3 {
+		t.Errorf("expected 3 tasks on the start date, got %d", len(taskList))
+	}
+}
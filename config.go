@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,8 +15,10 @@ import (
 // readConfiguration reads a given main configuration file
 func readConfiguration(c *cli.Context) error {
 	var (
-		yamlFile []byte
-		err      error
+		err  error
+		root *yaml.Node
+		ok   bool
+		i    int
 	)
 
 	glg.Infof("reading main configuration file")
@@ -32,26 +33,21 @@ func readConfiguration(c *cli.Context) error {
 
 	glg.Debugf("main config file path: %s", configFile)
 
-	yamlFile, err = ioutil.ReadFile(configFile)
-	if err != nil {
-		return fmt.Errorf("failed to load config file: %s", err.Error())
-	}
-
-	err = yaml.Unmarshal(yamlFile, &config)
-	if err != nil {
-		return fmt.Errorf("failed to parse config file: %s", err.Error())
+	config = new(configuration)
+	if root, ok = decodeYAMLFile(configFile, config); !ok {
+		return fmt.Errorf("failed to parse config file")
 	}
 
 	// userDN and userPassword are set by cli arguments and shouldn't be overwritten
 	// sanity check
 	if config.UserDN == nil && userDN == "" {
-		return fmt.Errorf("user_dn is not set in config file or supplied as argument")
+		addConfigError(configFile, root, "user_dn is not set in config file or supplied as argument")
 	} else if userDN != "" {
 		config.UserDN = &userDN
 	}
 
 	if config.UserPassword == nil && userPassword == "" {
-		return fmt.Errorf("user_password is not set in config file or supplied as argument")
+		addConfigError(configFile, root, "user_password is not set in config file or supplied as argument")
 	} else if userPassword != "" {
 		config.UserPassword = &userPassword
 	}
@@ -62,11 +58,11 @@ func readConfiguration(c *cli.Context) error {
 	}
 
 	if config.HostURI == nil {
-		return fmt.Errorf("missing required config `host_uri`")
+		addConfigError(configFile, root, "missing required config `host_uri`")
 	}
 
 	if config.GroupDir == nil {
-		return fmt.Errorf("missing required config `group_dir`")
+		addConfigError(configFile, root, "missing required config `group_dir`")
 	} else {
 		// if relative, make it absolute
 		if !filepath.IsAbs(*config.GroupDir) {
@@ -75,7 +71,7 @@ func readConfiguration(c *cli.Context) error {
 	}
 
 	if config.PeopleDir == nil {
-		return fmt.Errorf("missing required config `localPeople_dir`")
+		addConfigError(configFile, root, "missing required config `people_dir`")
 	} else {
 		// if relative, make it absolute
 		if !filepath.IsAbs(*config.PeopleDir) {
@@ -83,8 +79,26 @@ func readConfiguration(c *cli.Context) error {
 		}
 	}
 
+	if config.ExpiryWarningDays == nil {
+		config.ExpiryWarningDays = new(int)
+		*config.ExpiryWarningDays = 14
+	} else if *config.ExpiryWarningDays < 0 {
+		addConfigError(configFile, yamlPosition(root, "membership_expiry_warning_days"),
+			"membership_expiry_warning_days must not be negative")
+	}
+
+	// roles are optional
+	if config.RolesDir != nil && !filepath.IsAbs(*config.RolesDir) {
+		*config.RolesDir = filepath.Join(filepath.Dir(configFile), *config.RolesDir)
+	}
+
 	if config.RootDN == nil {
-		return fmt.Errorf("missing required config `root_dn`")
+		addConfigError(configFile, root, "missing required config `root_dn`")
+	}
+
+	// the remaining configuration can't be read without the required values above
+	if len(configErrors) > 0 {
+		return fmt.Errorf("main config file contains errors")
 	}
 
 	if config.PeopleRDN != nil {
@@ -107,8 +121,8 @@ func readConfiguration(c *cli.Context) error {
 	glg.Debugf("              group_dir: %s", *config.GroupDir)
 	glg.Debugf("             people_dir: %s", *config.PeopleDir)
 	glg.Debugf("                root_dn: %s", *config.RootDN)
-	glg.Debugf("             people_rdn: %s", *config.PeopleRDN)
-	glg.Debugf("              group_rdn: %s", *config.GroupRDN)
+	glg.Debugf("              people_dn: %s", peopleDN)
+	glg.Debugf("               group_dn: %s", groupDN)
 	glg.Debugf("           generate_uid: %t", config.GenerateUID)
 
 	if config.GenerateUID {
@@ -144,14 +158,42 @@ func readConfiguration(c *cli.Context) error {
 		glg.Debugf("(default) user_password: %s", *config.Defaults.UserPassword)
 	}
 
+	// the password policy applies to defaults as well
+	checkPasswordPolicyConfig(yamlMappingValue(root, "password_policy"))
+
+	// verify default templates can be parsed
+	checkDefaults(configFile, yamlMappingValue(root, "defaults"), &config.Defaults)
+
+	// verify transliteration only applies to templated attributes
+	for i = range config.Transliteration.Attributes {
+		if !isDefaultAttribute(config.Transliteration.Attributes[i]) {
+			addConfigError(configFile, yamlSequenceItem(yamlMappingValue(yamlMappingValue(root, "transliteration"), "attributes"), i),
+				"transliteration can't be applied to '%s', supported attributes are %s",
+				config.Transliteration.Attributes[i], strings.Join(defaultAttributes, ", "))
+		}
+	}
+
+	if _, ok = config.Transliteration.Rules[""]; ok {
+		addConfigError(configFile, yamlMappingValue(root, "transliteration"), "transliteration rules can't replace an empty string")
+	}
+
+	initTransliteration()
+
+	initSchema(yamlMappingValue(root, "schema"))
+	checkAttributeSchema(yamlMappingValue(yamlMappingValue(root, "schema"), "attributes"))
+	checkAttributePolicy(configFile, yamlMappingValue(root, "attribute_policy"), config.AttributePolicy)
+
 	glg.Infof("done reading main configuration file")
 
 	// init maps
 	localPeople = make(map[string]posixGroup)
 	localGroups = make(map[string]groupOfNames)
+	localUnixGroups = make(map[string]posixGroup)
+	localRoles = make(map[string]role)
 
 	ldapPeople = make(map[string]posixGroup)
 	ldapGroups = make(map[string]groupOfNames)
+	ldapUnixGroups = make(map[string]posixGroup)
 
 	return nil
 }
@@ -159,22 +201,33 @@ func readConfiguration(c *cli.Context) error {
 // readPeopleConfiguration reads a localPeople config dir and performs some basic sanity checks
 func readPeopleConfiguration() error {
 	var (
-		err           error
-		files         []string
-		currentFile   string
-		dn            string
-		yamlFile      []byte
-		currentPeople *posixGroup
-		userIndex     int
-		knownUsers    []string
-		i             int
-		ok            bool
-		pathPieces    []string
-		relPath       string
+		err            error
+		files          []string
+		defaultsFiles  []string
+		dirDefaults    map[string]*defaultsLayer
+		layers         []*defaultsLayer
+		currentFile    string
+		root           *yaml.Node
+		currentPeople  *posixGroup
+		groups         []*posixGroup
+		userIndex      int
+		user           *posixAccount
+		objects        []posixAccount
+		knownUsers     map[string]*posixAccount
+		known          *posixAccount
+		takenUsernames map[string]bool
+		data           map[string]interface{}
+		ok             bool
+		pathPieces     []string
+		relPath        string
+		violation      string
 	)
 
 	glg.Infof("reading people configuration file")
 
+	knownUsers = make(map[string]*posixAccount)
+	takenUsernames = make(map[string]bool)
+
 	err = filepath.Walk(filepath.Join(*config.PeopleDir),
 		func(path string, info os.FileInfo, err error) error {
 			var (
@@ -210,6 +263,10 @@ func readPeopleConfiguration() error {
 					localOUs = append(localOUs, ou)
 				}
 
+			} else if info.Name() == defaultsFileName {
+				// directory defaults are not a posixGroup
+				defaultsFiles = append(defaultsFiles, path)
+
 			} else {
 				// only collect files for below
 				files = append(files, path)
@@ -221,22 +278,34 @@ func readPeopleConfiguration() error {
 		return err
 	}
 
+	dirDefaults = readDirectoryDefaults(defaultsFiles)
+
 	for _, currentFile = range files {
 
 		glg.Infof("reading local people config file %s", currentFile)
 
-		yamlFile, err = ioutil.ReadFile(currentFile)
-		if err != nil {
-			return fmt.Errorf("failed to load user config file: '%s'", err.Error())
-		}
-
 		// currentPeople needs to be reset before every Unmarshal
 		currentPeople = new(posixGroup)
-		err = yaml.Unmarshal(yamlFile, currentPeople)
-		if err != nil {
-			return fmt.Errorf("failed to parse config file: '%s'", err.Error())
+		if root, ok = decodeYAMLFile(currentFile, currentPeople); !ok {
+			continue
 		}
 
+		currentPeople.file = currentFile
+		currentPeople.node = root
+		groups = append(groups, currentPeople)
+
+		// collect all explicitly configured usernames first so generated usernames never take them
+		for userIndex = range currentPeople.Objects {
+			if currentPeople.Objects[userIndex].UID != nil {
+				takenUsernames[*currentPeople.Objects[userIndex].UID] = true
+			}
+		}
+	}
+
+	for _, currentPeople = range groups {
+		currentFile = currentPeople.file
+		root = currentPeople.node
+
 		// unless cn has been specifically set, set cn based on file name
 		if currentPeople.CN == "" {
 			currentPeople.CN = filepath.Base(currentFile)
@@ -252,11 +321,12 @@ func readPeopleConfiguration() error {
 		}
 
 		if currentPeople.GIDNumber == nil {
-			glg.Fatalf("gid_number missing in '%s'", currentFile)
+			addConfigError(currentFile, root, "gid_number missing")
 		}
 
-		if _, ok = localPeople[dn]; ok {
-			return fmt.Errorf("dn %s already exists but was declared again in %s", dn, currentFile)
+		if _, ok = localPeople[currentPeople.dn]; ok {
+			addConfigError(currentFile, yamlPosition(root, "cn"), "dn %s already exists but was declared again",
+				currentPeople.dn)
 		}
 
 		// set dummy description
@@ -264,117 +334,114 @@ func readPeopleConfiguration() error {
 			currentPeople.Description = "managed by Monban"
 		}
 
+		if currentPeople.Defaults != nil {
+			checkDefaults(currentFile, yamlMappingValue(root, "defaults"), currentPeople.Defaults)
+		}
+
+		// defaults are applied from the most specific to the most generic layer
+		layers = defaultsLayers(currentPeople, dirDefaults)
+
+		// only objects without errors are kept
+		objects = nil
+
 		// sanity check user objects
 		for userIndex = range currentPeople.Objects {
-			if currentPeople.Objects[userIndex].UID == nil ||
-				currentPeople.Objects[userIndex].GivenName == nil ||
-				currentPeople.Objects[userIndex].Surname == nil {
-				return fmt.Errorf("user object with index '%d' in DN '%s' is missing one or more required fields", userIndex, currentFile)
+			user = &currentPeople.Objects[userIndex]
+			user.file = currentFile
+			user.node = yamlSequenceItem(yamlMappingValue(root, "objects"), userIndex)
+			user.sources = make(map[string]string)
+
+			if user.GivenName == nil || user.Surname == nil {
+				addConfigError(currentFile, user.node, "user object with index '%d' is missing one or more required fields (given_name, surname)", userIndex)
+				continue
+			}
+
+			// username can be generated from a pattern
+			if user.UID == nil {
+				if !generateUsername(user, currentPeople, layers, takenUsernames) {
+					continue
+				}
 			}
 
 			// set dn
-			currentPeople.Objects[userIndex].dn = fmt.Sprintf("uid=%s,%s", *currentPeople.Objects[userIndex].UID, currentPeople.dn)
+			user.dn = fmt.Sprintf("uid=%s,%s", *user.UID, currentPeople.dn)
 
 			// when UID generation is disabled the UID must be set in file
 			if !config.GenerateUID {
-				if currentPeople.Objects[userIndex].UIDNumber == nil {
-					glg.Fatalf("uid_number required because generate_uid is disabled but no value was given")
+				if user.UIDNumber == nil {
+					addConfigError(currentFile, user.node, "uid_number required because generate_uid is disabled but no value was given")
 				}
 			}
 
 			// when GID is already known set user
-			if currentPeople.Objects[userIndex].GIDNumber == nil {
-				currentPeople.Objects[userIndex].GIDNumber = currentPeople.GIDNumber
+			if user.GIDNumber == nil {
+				user.GIDNumber = currentPeople.GIDNumber
 			}
 
 			if *config.EnableSSHPublicKeys {
-				if currentPeople.Objects[userIndex].SSHPublicKey != nil {
+				if user.SSHPublicKey != nil {
 					// validate data is indeed a valid ssh key
-					_, _, _, _, err = ssh.ParseAuthorizedKey([]byte(*currentPeople.Objects[userIndex].SSHPublicKey))
+					_, _, _, _, err = ssh.ParseAuthorizedKey([]byte(*user.SSHPublicKey))
 
 					if err != nil {
-						glg.Errorf("failed to parse ssh_public_key: %s", err.Error())
-						// remove ssh key from entry
-						currentPeople.Objects[userIndex].SSHPublicKey = nil
+						addConfigError(currentFile, yamlPosition(user.node, "ssh_public_key"), "failed to parse ssh_public_key: %s", err.Error())
 					}
 				}
 			}
 
 			// add defaults if not otherwise configured
-			if currentPeople.Objects[userIndex].DisplayName == nil {
-				if config.Defaults.DisplayName == nil {
-					return fmt.Errorf("cannot read object: display_name not set in object and no default is defined")
+			data = templateData(currentPeople, user)
+			applyDefault(user, "display_name", &user.DisplayName, layers, data)
+			applyDefault(user, "login_shell", &user.LoginShell, layers, data)
+			applyDefault(user, "mail", &user.Mail, layers, data)
+			applyDefault(user, "home_dir", &user.HomeDir, layers, data)
+			applyDefault(user, "user_password", &user.UserPassword, layers, data)
+
+			// passwords from defaults have been checked with their layer already
+			if _, ok = user.sources["user_password"]; !ok && user.UserPassword != nil {
+				if violation = passwordPolicyViolation(*user.UserPassword); violation != "" {
+					addConfigError(currentFile, yamlPosition(user.node, "user_password"), "invalid user_password: %s",
+						violation)
 				}
-
-				// construct from default
-				currentPeople.Objects[userIndex].DisplayName = new(string)
-				// set given_name
-				*currentPeople.Objects[userIndex].DisplayName = strings.ReplaceAll(*config.Defaults.DisplayName, "%g", *currentPeople.Objects[userIndex].GivenName)
-				// set surname
-				*currentPeople.Objects[userIndex].DisplayName = strings.ReplaceAll(*currentPeople.Objects[userIndex].DisplayName, "%l", *currentPeople.Objects[userIndex].Surname)
-				// set username
-				*currentPeople.Objects[userIndex].DisplayName = strings.ReplaceAll(*currentPeople.Objects[userIndex].DisplayName, "%u", *currentPeople.Objects[userIndex].UID)
 			}
 
-			if currentPeople.Objects[userIndex].LoginShell == nil {
-				if config.Defaults.LoginShell == nil {
-					return fmt.Errorf("cannot read object: login_shell not set in object and no default is defined")
+			if user.Mail != nil {
+				if err = validateMail(*user.Mail); err != nil {
+					addConfigError(currentFile, valuePosition(user, "mail"), "invalid mail '%s' (%s): %s",
+						*user.Mail, valueSource(user, "mail"), err.Error())
 				}
-				currentPeople.Objects[userIndex].LoginShell = config.Defaults.LoginShell
 			}
 
-			if currentPeople.Objects[userIndex].Mail == nil {
-				if config.Defaults.Mail == nil {
-					return fmt.Errorf("cannot read object: mail not set in object and no default is defined")
-				}
+			checkAttributes(currentFile, yamlMappingValue(user.node, "attributes"), user.Attributes,
+				objectTypePosixAccount, data)
+			checkAttributePolicy(currentFile, yamlMappingValue(user.node, "attribute_policy"), user.AttributePolicy)
 
-				// construct from default
-				currentPeople.Objects[userIndex].Mail = new(string)
-				// set given_name
-				*currentPeople.Objects[userIndex].Mail = strings.ReplaceAll(*config.Defaults.Mail, "%g", strings.ToLower(*currentPeople.Objects[userIndex].GivenName))
-				// set surname
-				*currentPeople.Objects[userIndex].Mail = strings.ReplaceAll(*currentPeople.Objects[userIndex].Mail, "%l", strings.ToLower(*currentPeople.Objects[userIndex].Surname))
-				// set username
-				*currentPeople.Objects[userIndex].Mail = strings.ReplaceAll(*currentPeople.Objects[userIndex].Mail, "%u", strings.ToLower(*currentPeople.Objects[userIndex].UID))
+			if user.StartDate != nil && user.Expires != nil && !user.StartDate.Before(*user.Expires) {
+				addConfigError(currentFile, yamlPosition(user.node, "start_date"), "start_date must be before expires")
 			}
 
-			if currentPeople.Objects[userIndex].HomeDir == nil {
-				if config.Defaults.HomeDir == nil {
-					return fmt.Errorf("cannot read object: home_dir not set in object and no default is defined")
+			if user.HomeDir != nil {
+				if err = validateHomeDir(*user.HomeDir); err != nil {
+					addConfigError(currentFile, valuePosition(user, "home_dir"), "invalid home_dir '%s' (%s): %s",
+						*user.HomeDir, valueSource(user, "home_dir"), err.Error())
 				}
-
-				// construct from default
-				currentPeople.Objects[userIndex].HomeDir = new(string)
-				// set given_name
-				*currentPeople.Objects[userIndex].HomeDir = strings.ReplaceAll(*config.Defaults.HomeDir, "%g", *currentPeople.Objects[userIndex].GivenName)
-				// set surname
-				*currentPeople.Objects[userIndex].HomeDir = strings.ReplaceAll(*currentPeople.Objects[userIndex].HomeDir, "%l", *currentPeople.Objects[userIndex].Surname)
-				// set username
-				*currentPeople.Objects[userIndex].HomeDir = strings.ReplaceAll(*currentPeople.Objects[userIndex].HomeDir, "%u", *currentPeople.Objects[userIndex].UID)
-			}
-
-			if currentPeople.Objects[userIndex].UserPassword == nil {
-				if config.Defaults.UserPassword == nil {
-					return fmt.Errorf("cannot read object: user_password not set in object and no default is defined")
-				}
-				// construct from default
-				currentPeople.Objects[userIndex].UserPassword = new(string)
-				// set username
-				*currentPeople.Objects[userIndex].UserPassword = strings.ReplaceAll(*config.Defaults.UserPassword, "%u", *currentPeople.Objects[userIndex].UID)
 			}
 
 			// verify the same user isn't configured multiple times
-			for i = range knownUsers {
-				if knownUsers[i] == *currentPeople.Objects[userIndex].UID {
-					return fmt.Errorf("user with username '%s' is configured multiple times", *currentPeople.Objects[userIndex].UID)
-				}
+			if known, ok = knownUsers[*user.UID]; ok {
+				addConfigError(currentFile, yamlPosition(user.node, "username"), "user with username '%s' is already configured in %s:%d",
+					*user.UID, known.file, known.node.Line)
+				continue
 			}
 
 			// adding id to now known users
-			knownUsers = append(knownUsers, *currentPeople.Objects[userIndex].UID)
-			glg.Debugf("loaded local user with DN %s", currentPeople.Objects[userIndex].dn)
+			knownUsers[*user.UID] = user
+			objects = append(objects, *user)
+			glg.Debugf("loaded local user with DN %s", user.dn)
 		}
 
+		currentPeople.Objects = objects
+
 		// add loaded file to global list of know user objects
 		localPeople[currentPeople.dn] = *currentPeople
 	}
@@ -390,17 +457,33 @@ func readGroupConfiguration() error {
 		files        []string
 		currentFile  string
 		currentGroup *groupOfNames
-		yamlFile     []byte
+		root         *yaml.Node
+		membersNode  *yaml.Node
+		ok           bool
 		i            int
 		j            int
 		match        int
 		dn           string
 		pathPieces   []string
 		relPath      string
+		gids         map[int]string
+		known        string
+		paths        map[string]string
 	)
 
 	glg.Infof("reading group configuration file")
 
+	// gid numbers must be unique among posixGroups of people files and groups
+	gids = make(map[int]string)
+	for dn = range localPeople {
+		if localPeople[dn].GIDNumber != nil {
+			gids[*localPeople[dn].GIDNumber] = dn
+		}
+	}
+
+	// paths maps the path of every group file relative to group_dir to the DN of its group
+	paths = make(map[string]string)
+
 	err = filepath.Walk(filepath.Join(*config.GroupDir),
 		func(path string, info os.FileInfo, err error) error {
 			var (
@@ -449,18 +532,15 @@ func readGroupConfiguration() error {
 	for _, currentFile = range files {
 		glg.Infof("reading group config file %s", currentFile)
 
-		yamlFile, err = ioutil.ReadFile(currentFile)
-		if err != nil {
-			return fmt.Errorf("failed to load group config file: %s", err.Error())
-		}
-
 		// currentGroup needs to be reset before every Unmarshal
 		currentGroup = new(groupOfNames)
-		err = yaml.Unmarshal(yamlFile, currentGroup)
-		if err != nil {
-			return fmt.Errorf("failed to parse group config file: %s", err.Error())
+		if root, ok = decodeYAMLFile(currentFile, currentGroup); !ok {
+			continue
 		}
 
+		currentGroup.file = currentFile
+		currentGroup.node = root
+
 		// CN wasn't explicitly set, using filename instead
 		if currentGroup.CN == "" {
 			currentGroup.CN = filepath.Base(currentFile)
@@ -474,54 +554,147 @@ func readGroupConfiguration() error {
 		} else {
 			currentGroup.dn = fmt.Sprintf("cn=%s,%s,%s", currentGroup.CN, generateOUDN(pathPieces[:len(pathPieces)-1]), groupDN)
 		}
-		glg.Debugf("loaded local group with DN %s", currentGroup.dn)
+
+		if _, ok = localGroups[currentGroup.dn]; !ok {
+			_, ok = localUnixGroups[currentGroup.dn]
+		}
+
+		if ok {
+			addConfigError(currentFile, yamlPosition(root, "cn"), "dn %s already exists but was declared again",
+				currentGroup.dn)
+		}
+
+		paths[relPath] = currentGroup.dn
 
 		// check if description is set
 		if currentGroup.Description == "" {
 			currentGroup.Description = "Managed by Monban"
 		}
 
+		switch currentGroup.Type {
+		case "", "groupOfNames":
+			currentGroup.Type = "groupOfNames"
+
+		case "posixGroup":
+			if currentGroup.GIDNumber == nil {
+				addConfigError(currentFile, root, "gid_number is required for groups of type posixGroup")
+			}
+
+			if currentGroup.Attributes != nil {
+				addConfigError(currentFile, yamlKeyPosition(root, "attributes"), "attributes are not supported for groups of type posixGroup")
+				currentGroup.Attributes = nil
+			}
+
+		default:
+			addConfigError(currentFile, yamlPosition(root, "type"), "unknown group type '%s', supported types are groupOfNames, posixGroup",
+				currentGroup.Type)
+		}
+
+		if currentGroup.GIDNumber != nil {
+			if currentGroup.Type == "groupOfNames" && !hybridGroupsSupported() {
+				addConfigError(currentFile, yamlPosition(root, "gid_number"),
+					"gid_number on groups requires a schema profile with auxiliary posixGroup (e.g. openldap-rfc2307bis)")
+			}
+
+			if known, ok = gids[*currentGroup.GIDNumber]; ok {
+				addConfigError(currentFile, yamlPosition(root, "gid_number"), "gid_number %d is already used by %s",
+					*currentGroup.GIDNumber, known)
+			} else {
+				gids[*currentGroup.GIDNumber] = currentGroup.dn
+			}
+		}
+
+		checkAttributes(currentFile, yamlMappingValue(root, "attributes"), currentGroup.Attributes,
+			objectTypeGroupOfNames, groupTemplateData(currentGroup))
+
+		if currentGroup.Type == "posixGroup" && currentGroup.Owners != nil {
+			addConfigError(currentFile, yamlKeyPosition(root, "owners"), "owners are not supported for groups of type posixGroup")
+			currentGroup.Owners = nil
+		}
+
+		checkGroupOwners(currentFile, yamlMappingValue(root, "owners"), currentGroup)
+
+		if currentGroup.Type == "posixGroup" && currentGroup.MemberRules != nil {
+			addConfigError(currentFile, yamlKeyPosition(root, "member_rules"), "member_rules are not supported for groups of type posixGroup")
+			currentGroup.MemberRules = nil
+		}
+
+		checkMemberRules(currentFile, yamlMappingValue(root, "member_rules"), currentGroup.MemberRules)
+
+		membersNode = yamlMappingValue(root, "members")
+
 		// verify members are only added once
 		for i = range currentGroup.Members {
-			match = 0
-			for j = range currentGroup.Members {
-				if currentGroup.Members[i] == currentGroup.Members[j] {
-					match++
-				}
-
-				if match > 1 {
-					return fmt.Errorf("duplicated member entry with uid %s in group '%s'", currentGroup.Members[i], currentGroup.dn)
+			for j = 0; j < i; j++ {
+				if currentGroup.Members[i].Member != "" && currentGroup.Members[i].Member == currentGroup.Members[j].Member {
+					addConfigError(currentFile, yamlSequenceItem(membersNode, i), "duplicated member entry with uid %s",
+						currentGroup.Members[i].Member)
+					break
 				}
 			}
 
-			// add member with DN not UID
-
-			glg.Debugf("loaded group member with uid %s", currentGroup.Members[i])
+			glg.Debugf("loaded group member with uid %s", currentGroup.Members[i].Member)
 		}
 
+		checkMemberPolicy(currentFile, yamlMappingValue(root, "member_policy"), currentGroup.MemberPolicy)
+		checkGroupMembers(currentFile, currentGroup.Members, currentGroup.MemberPolicy)
+
 		// verify members also exist within config
 		for i = range currentGroup.Members {
+			// group references are resolved once all groups are known
+			if strings.HasPrefix(currentGroup.Members[i].Member, groupMemberPrefix) {
+				if currentGroup.Type == "posixGroup" {
+					addConfigError(currentFile, yamlSequenceItem(membersNode, i),
+						"groups of type posixGroup can't have groups as members")
+				}
+
+				continue
+			}
+
 			match = 0
 			for dn = range localPeople {
 				for j = range localPeople[dn].Objects {
 
-					if currentGroup.Members[i] == *localPeople[dn].Objects[j].UID {
+					if currentGroup.Members[i].Member == *localPeople[dn].Objects[j].UID {
 						match++
 						break
 					}
 				}
 			}
 
-			if match == 0 {
-				return fmt.Errorf("member uid %s in group %s doesn't exist as user object", currentGroup.Members[i], currentGroup.dn)
+			// entries without member have already been reported
+			if match == 0 && currentGroup.Members[i].Member != "" {
+				addConfigError(currentFile, yamlSequenceItem(membersNode, i), "member uid %s doesn't exist as user object",
+					currentGroup.Members[i].Member)
 			}
 		}
 
+		if currentGroup.Type == "posixGroup" {
+			// unix groups only have the usernames of their members (memberUid)
+			localUnixGroups[currentGroup.dn] = posixGroup{
+				dn:          currentGroup.dn,
+				file:        currentGroup.file,
+				node:        currentGroup.node,
+				CN:          currentGroup.CN,
+				GIDNumber:   currentGroup.GIDNumber,
+				Description: currentGroup.Description,
+				members:     currentGroup.Members,
+				memberUIDs:  activeMembers(currentGroup.Members, now),
+			}
+			glg.Debugf("loaded local posixGroup with DN %s", currentGroup.dn)
+			continue
+		}
+
 		// add group to global list of groups
 		localGroups[currentGroup.dn] = *currentGroup
 		glg.Debugf("loaded local group with DN %s", currentGroup.dn)
 	}
 
+	// groups can reference groups of files read later on, so members are resolved once all groups are known
+	resolveGroupMembers(paths)
+	resolveRoles(paths)
+	checkGroupCycles()
+
 	glg.Infof("done reading group configuration file")
 	return nil
 }
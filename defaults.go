@@ -0,0 +1,266 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/kpango/glg"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultsFileName is the name of files within people_dir that define defaults for all people files in the same
+// directory and all sub directories
+const defaultsFileName = ".defaults"
+
+// defaultAttributes contains the names of all attributes that can have a default
+var defaultAttributes = []string{"username", "display_name", "login_shell", "mail", "home_dir", "user_password"}
+
+// defaultsLayer is one level of defaults
+// precedence of layers is object > people file > directory (deepest first) > main config
+type defaultsLayer struct {
+	// name describes where the defaults are defined, used to tell where a value came from
+	name   string
+	values *defaults
+}
+
+// get returns the default of an attribute or nil if it isn't set
+func (d *defaults) get(name string) *string {
+	if d == nil {
+		return nil
+	}
+
+	switch name {
+	case "username":
+		return d.Username
+	case "display_name":
+		return d.DisplayName
+	case "login_shell":
+		return d.LoginShell
+	case "mail":
+		return d.Mail
+	case "home_dir":
+		return d.HomeDir
+	case "user_password":
+		return d.UserPassword
+	}
+
+	return nil
+}
+
+// readDirectoryDefaults reads all directory defaults files and returns them indexed by their directory
+func readDirectoryDefaults(files []string) map[string]*defaultsLayer {
+	var (
+		layers map[string]*defaultsLayer
+		layer  *defaultsLayer
+		file   string
+		root   *yaml.Node
+		ok     bool
+	)
+
+	layers = make(map[string]*defaultsLayer)
+
+	for _, file = range files {
+		glg.Infof("reading directory defaults file %s", file)
+
+		layer = new(defaultsLayer)
+		layer.name = relativeConfigPath(file)
+		layer.values = new(defaults)
+
+		if root, ok = decodeYAMLFile(file, layer.values); !ok {
+			continue
+		}
+
+		checkDefaults(file, root, layer.values)
+		layers[filepath.Dir(file)] = layer
+	}
+
+	return layers
+}
+
+// defaultsLayers returns all layers of defaults that apply to objects of a people file ordered by precedence
+func defaultsLayers(group *posixGroup, dirDefaults map[string]*defaultsLayer) []*defaultsLayer {
+	var (
+		layers []*defaultsLayer
+		layer  *defaultsLayer
+		dir    string
+		ok     bool
+	)
+
+	if group.Defaults != nil {
+		layers = append(layers, &defaultsLayer{
+			name:   relativeConfigPath(group.file),
+			values: group.Defaults,
+		})
+	}
+
+	// walk up the directory tree until people_dir is reached
+	dir = filepath.Dir(group.file)
+	for {
+		if layer, ok = dirDefaults[dir]; ok {
+			layers = append(layers, layer)
+		}
+
+		if dir == filepath.Clean(*config.PeopleDir) || dir == filepath.Dir(dir) {
+			break
+		}
+
+		dir = filepath.Dir(dir)
+	}
+
+	layers = append(layers, &defaultsLayer{
+		name:   "main config",
+		values: &config.Defaults,
+	})
+
+	return layers
+}
+
+// checkDefaults verifies that all default templates of a layer can be parsed
+// node is the mapping node containing the defaults and only used for error positions
+func checkDefaults(file string, node *yaml.Node, d *defaults) {
+	var (
+		name string
+		tmpl *string
+		err  error
+	)
+
+	for _, name = range defaultAttributes {
+		if tmpl = d.get(name); tmpl == nil {
+			continue
+		}
+
+		if _, err = parseTemplate(defaultTemplate(name, *tmpl)); err != nil {
+			addConfigError(file, yamlPosition(node, name), "invalid default template for %s: %s", name, err.Error())
+			continue
+		}
+
+		if name == "user_password" {
+			checkDefaultPassword(file, yamlPosition(node, name), *tmpl)
+		}
+	}
+}
+
+// applyDefault sets value of a user object from the first layer of defaults that defines the attribute unless it was
+// explicitly set in the object
+// attributes with policy ignore don't need a value; set_on_create attributes only need one if the object is created,
+// which is checked by missingAttributes when comparing with LDAP
+func applyDefault(user *posixAccount, name string, value **string, layers []*defaultsLayer, data map[string]interface{}) {
+	var (
+		layer    *defaultsLayer
+		tmpl     *string
+		rendered string
+		err      error
+	)
+
+	if *value != nil {
+		return
+	}
+
+	for _, layer = range layers {
+		if tmpl = layer.values.get(name); tmpl != nil {
+			break
+		}
+	}
+
+	if tmpl == nil {
+		if user.managed(name) {
+			addConfigError(user.file, user.node, "%s not set in object and no default is defined", name)
+		}
+		return
+	}
+
+	// broken templates are already reported when reading the defaults
+	if _, err = parseTemplate(defaultTemplate(name, *tmpl)); err != nil {
+		return
+	}
+
+	if rendered, err = renderTemplate(defaultTemplate(name, *tmpl), data); err != nil {
+		addConfigError(user.file, user.node, "failed to generate %s from default template of %s: %s", name, layer.name,
+			err.Error())
+		return
+	}
+
+	if transliterateAttribute(name) {
+		rendered = transliterate(rendered)
+	}
+
+	*value = &rendered
+	user.sources[name] = layer.name
+}
+
+// isDefaultAttribute returns true if name is an attribute that can have a default
+func isDefaultAttribute(name string) bool {
+	var attribute string
+
+	for _, attribute = range defaultAttributes {
+		if attribute == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// relativeConfigPath returns the path of a config file relative to the main config file for display purposes
+func relativeConfigPath(file string) string {
+	var (
+		relPath string
+		err     error
+	)
+
+	if relPath, err = filepath.Rel(basePath, file); err != nil {
+		return file
+	}
+
+	return relPath
+}
+
+// defaultValue returns the value of an attribute that can have a default, nil if it isn't set
+func (u *posixAccount) defaultValue(name string) *string {
+	switch name {
+	case "username":
+		return u.UID
+	case "display_name":
+		return u.DisplayName
+	case "login_shell":
+		return u.LoginShell
+	case "mail":
+		return u.Mail
+	case "home_dir":
+		return u.HomeDir
+	case "user_password":
+		return u.UserPassword
+	}
+
+	return nil
+}
+
+// valueSource describes where the value of an attribute of a user object came from
+func valueSource(user *posixAccount, name string) string {
+	var (
+		source string
+		ok     bool
+	)
+
+	if source, ok = user.sources[name]; ok {
+		return "default from " + source
+	}
+
+	// attributes that aren't managed don't need a value
+	if user.defaultValue(name) == nil {
+		return "not set, " + user.attributePolicy(name)
+	}
+
+	return "set in object"
+}
+
+// valuePosition returns the position of an attribute within the config file or the object itself when the value came
+// from a default
+func valuePosition(user *posixAccount, name string) *yaml.Node {
+	var ok bool
+
+	if _, ok = user.sources[name]; ok {
+		return user.node
+	}
+
+	return yamlPosition(user.node, name)
+}
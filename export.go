@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// auditFormats contains all supported output formats of the audit command
+var auditFormats = []string{"text", "csv", "json", "markdown", "html"}
+
+// auditCSVHeader contains the columns of CSV exports of the users view, user columns are repeated for every membership
+var auditCSVHeader = []string{
+	"username", "dn", "posix_group", "given_name", "surname", "display_name", "login_shell", "mail", "home_dir",
+	"roles", "attributes", "user_drift", "group", "group_type", "via", "until", "reason", "ticket", "approved_by",
+	"membership_drift",
+}
+
+// auditGroupsCSVHeader contains the columns of CSV exports of the groups view, group columns are repeated for every
+// member
+var auditGroupsCSVHeader = []string{
+	"group", "cn", "group_type", "description", "owners", "member_count", "group_drift", "username", "via", "until",
+	"membership_drift",
+}
+
+// pageNameInvalid matches all characters not allowed in names of HTML report pages
+var pageNameInvalid = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// writeAudit renders the audit report in the given format and view to out
+// out is a file for all formats but html which needs a directory; an empty out writes to stdout
+// json and html always contain both views
+func writeAudit(r *auditReport, format string, view string, out string) error {
+	var (
+		err  error
+		file *os.File
+		w    io.Writer
+	)
+
+	if format == "html" {
+		if out == "" {
+			return fmt.Errorf("format html needs a directory set with --out")
+		}
+
+		return writeAuditHTML(out, r)
+	}
+
+	w = os.Stdout
+
+	if out != "" {
+		if file, err = os.Create(out); err != nil {
+			return fmt.Errorf("failed to create audit file: %s", err.Error())
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	switch {
+	case format == "text" && view == "groups":
+		writeAuditGroupsText(w, r)
+	case format == "text":
+		writeAuditText(w, r)
+	case format == "csv" && view == "groups":
+		err = writeAuditGroupsCSV(w, r)
+	case format == "csv":
+		err = writeAuditCSV(w, r)
+	case format == "json":
+		err = writeAuditJSON(w, r)
+	case format == "markdown" && view == "groups":
+		writeAuditGroupsMarkdown(w, r)
+	case format == "markdown":
+		writeAuditMarkdown(w, r)
+	default:
+		return fmt.Errorf("unknown audit format '%s', supported formats are %s", format, strings.Join(auditFormats, ", "))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write audit: %s", err.Error())
+	}
+
+	return nil
+}
+
+// writeAuditCSV writes one row per user and group pairing, users without any membership get a single row without group
+func writeAuditCSV(w io.Writer, r *auditReport) error {
+	var (
+		writer     *csv.Writer
+		user       *auditUser
+		membership *auditMembership
+		columns    []string
+		err        error
+	)
+
+	writer = csv.NewWriter(w)
+
+	if err = writer.Write(auditCSVHeader); err != nil {
+		return err
+	}
+
+	for _, user = range r.Users {
+		columns = []string{
+			user.Username, user.DN, user.PosixGroup, user.GivenName, user.Surname, user.DisplayName, user.LoginShell,
+			user.Mail, user.HomeDir, strings.Join(user.Roles, ";"), formatAuditAttributes(user.Attributes),
+			strings.Join(user.Drift, "; "),
+		}
+
+		if len(user.Memberships) == 0 {
+			if err = writer.Write(append(columns, "", "", "", "", "", "", "", "")); err != nil {
+				return err
+			}
+		}
+
+		for _, membership = range user.Memberships {
+			if err = writer.Write(append(columns, membership.Group, membership.Type, membership.Via,
+				membership.Until, membership.Reason, membership.Ticket, membership.ApprovedBy, membership.Drift)); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// writeAuditGroupsCSV writes one row per group and member pairing, empty groups get a single row without member
+func writeAuditGroupsCSV(w io.Writer, r *auditReport) error {
+	var (
+		writer  *csv.Writer
+		group   *auditGroup
+		member  *auditGroupMember
+		columns []string
+		err     error
+	)
+
+	writer = csv.NewWriter(w)
+
+	if err = writer.Write(auditGroupsCSVHeader); err != nil {
+		return err
+	}
+
+	for _, group = range r.Groups {
+		columns = []string{
+			group.DN, group.CN, group.Type, group.Description, strings.Join(group.Owners, ";"),
+			strconv.Itoa(group.MemberCount), group.Drift,
+		}
+
+		if group.MemberCount == 0 {
+			if err = writer.Write(append(columns, "", "", "", "")); err != nil {
+				return err
+			}
+		}
+
+		for _, member = range group.Members {
+			if err = writer.Write(append(columns, member.Username, member.Via, member.Until, member.Drift)); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// writeAuditJSON writes the whole audit report as indented JSON
+func writeAuditJSON(w io.Writer, r *auditReport) error {
+	var encoder *json.Encoder
+
+	encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// writeAuditMarkdown writes the audit report as Markdown tables
+func writeAuditMarkdown(w io.Writer, r *auditReport) {
+	var (
+		user       *auditUser
+		membership *auditMembership
+		group      *auditGroup
+		roleInfo   *auditRole
+		expiring   *auditExpiring
+	)
+
+	fmt.Fprintf(w, "# Monban Audit\n\nSource: %s\n\n## Users\n\n", r.Source)
+	fmt.Fprintf(w, "| Username | Name | Mail | Posix Group | Login Shell | Home Dir | Roles | Attributes | Drift |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|---|---|---|---|\n")
+
+	for _, user = range r.Users {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", markdownCell(user.Username),
+			markdownCell(user.DisplayName), markdownCell(user.Mail), markdownCell(user.PosixGroup),
+			markdownCell(user.LoginShell), markdownCell(user.HomeDir), markdownCell(strings.Join(user.Roles, ", ")),
+			markdownCell(formatAuditAttributes(user.Attributes)), markdownCell(strings.Join(user.Drift, "<br>")))
+	}
+
+	fmt.Fprintf(w, "\n## Memberships\n\n")
+	fmt.Fprintf(w, "| Username | Group | Type | Via | Until | Reason | Ticket | Approved By | Drift |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|---|---|---|---|\n")
+
+	for _, user = range r.Users {
+		for _, membership = range user.Memberships {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", markdownCell(user.Username),
+				markdownCell(membership.Group), membership.Type, markdownCell(membership.Via), membership.Until,
+				markdownCell(membership.Reason), markdownCell(membership.Ticket), markdownCell(membership.ApprovedBy),
+				membership.Drift)
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Groups\n\n")
+	fmt.Fprintf(w, "| Group | Type | Description | Owners | Members | Drift |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|---|\n")
+
+	for _, group = range r.Groups {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n", markdownCell(group.DN), group.Type,
+			markdownCell(group.Description), markdownCell(strings.Join(group.Owners, ", ")),
+			markdownCell(strings.Join(group.memberNames(), ", ")), markdownCell(group.Drift))
+	}
+
+	if len(r.Roles) > 0 {
+		fmt.Fprintf(w, "\n## Roles\n\n")
+		fmt.Fprintf(w, "| Role | Description | Groups | Sudoers | Users |\n")
+		fmt.Fprintf(w, "|---|---|---|---|---|\n")
+	}
+
+	for _, roleInfo = range r.Roles {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", markdownCell(roleInfo.Name), markdownCell(roleInfo.Description),
+			markdownCell(strings.Join(roleInfo.Groups, "<br>")), markdownCell(strings.Join(roleInfo.Sudoers, "<br>")),
+			markdownCell(strings.Join(roleInfo.Users, ", ")))
+	}
+
+	if len(r.Expiring) > 0 {
+		fmt.Fprintf(w, "\n## Memberships expiring within %d days\n\n", r.ExpiryWarningDays)
+		fmt.Fprintf(w, "| Until | Member | Group |\n")
+		fmt.Fprintf(w, "|---|---|---|\n")
+	}
+
+	for _, expiring = range r.Expiring {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", expiring.Until, markdownCell(expiring.Member), markdownCell(expiring.Group))
+	}
+}
+
+// writeAuditGroupsMarkdown writes the group-centric view of the audit report as Markdown tables
+func writeAuditGroupsMarkdown(w io.Writer, r *auditReport) {
+	var (
+		group  *auditGroup
+		member *auditGroupMember
+		name   string
+	)
+
+	fmt.Fprintf(w, "# Monban Audit\n\nSource: %s\n\n## Groups\n\n", r.Source)
+	fmt.Fprintf(w, "| Group | Type | Description | Owners | Members | Drift |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|---|\n")
+
+	for _, group = range r.Groups {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %d | %s |\n", markdownCell(group.DN), group.Type,
+			markdownCell(group.Description), markdownCell(strings.Join(group.Owners, ", ")), group.MemberCount,
+			markdownCell(group.Drift))
+	}
+
+	for _, group = range r.Groups {
+		if group.MemberCount == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n### %s\n\n", markdownCell(group.DN))
+		fmt.Fprintf(w, "| Username | Via | Until | Drift |\n")
+		fmt.Fprintf(w, "|---|---|---|---|\n")
+
+		for _, member = range group.Members {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", markdownCell(member.Username), markdownCell(member.Via),
+				member.Until, member.Drift)
+		}
+	}
+
+	if len(r.EmptyGroups) > 0 {
+		fmt.Fprintf(w, "\n## Empty Groups\n\n")
+	}
+
+	for _, name = range r.EmptyGroups {
+		fmt.Fprintf(w, "* %s\n", name)
+	}
+
+	if len(r.UsersWithoutMemberships) > 0 {
+		fmt.Fprintf(w, "\n## Users without Memberships\n\n")
+	}
+
+	for _, name = range r.UsersWithoutMemberships {
+		fmt.Fprintf(w, "* %s\n", name)
+	}
+}
+
+// markdownCell escapes a value to be used within a Markdown table cell
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(value)
+}
+
+// formatAuditAttributes returns additional attributes in a single line (name=value1,value2; name2=value)
+func formatAuditAttributes(attributes map[string]attributeValues) string {
+	var (
+		parts []string
+		name  string
+	)
+
+	for _, name = range sortedAttributeNames(attributes) {
+		parts = append(parts, name+"="+strings.Join(attributes[name], ","))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// auditPageName returns the file name of the HTML page of a user or group
+func auditPageName(name string) string {
+	return pageNameInvalid.ReplaceAllString(strings.ToLower(name), "_") + ".html"
+}
+
+// auditHTMLTemplates contains the pages of the HTML report: index (auditReport), user (auditUser) and group (auditGroup)
+var auditHTMLTemplates = template.Must(template.New("html").Funcs(template.FuncMap{
+	"page": auditPageName,
+	"join": strings.Join,
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monban Audit{{if .}} - {{.}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" ""}}<h1>Monban Audit</h1>
+<p>Source: {{.Source}}</p>
+<h2>Users</h2>
+<table>
+<tr><th>Username</th><th>Name</th><th>Mail</th><th>Posix Group</th><th>Roles</th><th>Memberships</th><th>Drift</th></tr>
+{{range .Users}}<tr><td><a href="users/{{page .Username}}">{{.Username}}</a></td><td>{{.DisplayName}}</td><td>{{.Mail}}</td><td>{{.PosixGroup}}</td><td>{{join .Roles ", "}}</td><td>{{len .Memberships}}</td><td>{{range .Drift}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+<h2>Groups</h2>
+<table>
+<tr><th>Group</th><th>Type</th><th>Description</th><th>Owners</th><th>Members</th><th>Drift</th></tr>
+{{range .Groups}}<tr><td><a href="groups/{{page .DN}}">{{.DN}}</a></td><td>{{.Type}}</td><td>{{.Description}}</td><td>{{range .Owners}}<a href="users/{{page .}}">{{.}}</a><br>{{end}}</td><td>{{.MemberCount}}</td><td>{{.Drift}}</td></tr>
+{{end}}</table>
+{{if .EmptyGroups}}<h2>Empty Groups</h2>
+<ul>
+{{range .EmptyGroups}}<li><a href="groups/{{page .}}">{{.}}</a></li>
+{{end}}</ul>
+{{end}}{{if .UsersWithoutMemberships}}<h2>Users without Memberships</h2>
+<ul>
+{{range .UsersWithoutMemberships}}<li><a href="users/{{page .}}">{{.}}</a></li>
+{{end}}</ul>
+{{end}}
+{{if .Roles}}<h2>Roles</h2>
+<table>
+<tr><th>Role</th><th>Description</th><th>Groups</th><th>Sudoers</th><th>Users</th></tr>
+{{range .Roles}}<tr><td>{{.Name}}</td><td>{{.Description}}</td><td>{{range .Groups}}<a href="groups/{{page .}}">{{.}}</a><br>{{end}}</td><td>{{range .Sudoers}}{{.}}<br>{{end}}</td><td>{{range .Users}}<a href="users/{{page .}}">{{.}}</a><br>{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Expiring}}<h2>Memberships expiring within {{.ExpiryWarningDays}} days</h2>
+<table>
+<tr><th>Until</th><th>Member</th><th>Group</th></tr>
+{{range .Expiring}}<tr><td>{{.Until}}</td><td>{{.Member}}</td><td><a href="groups/{{page .Group}}">{{.Group}}</a></td></tr>
+{{end}}</table>
+{{end}}{{template "footer"}}{{end}}
+
+{{define "user"}}{{template "header" .Username}}<p><a href="../index.html">Index</a></p>
+<h1>{{.Username}}</h1>
+<table>
+<tr><th>DN</th><td>{{.DN}}</td></tr>
+<tr><th>Posix Group</th><td>{{.PosixGroup}}</td></tr>
+<tr><th>Given Name</th><td>{{.GivenName}}</td></tr>
+<tr><th>Last Name</th><td>{{.Surname}}</td></tr>
+<tr><th>Display Name</th><td>{{.DisplayName}}</td></tr>
+<tr><th>Login Shell</th><td>{{.LoginShell}}</td></tr>
+<tr><th>Mail</th><td>{{.Mail}}</td></tr>
+<tr><th>Home Dir</th><td>{{.HomeDir}}</td></tr>
+<tr><th>Roles</th><td>{{join .Roles ", "}}</td></tr>
+{{range $name, $values := .Attributes}}<tr><th>{{$name}}</th><td>{{join $values ", "}}</td></tr>
+{{end}}{{if .Drift}}<tr><th>Drift</th><td>{{range .Drift}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+<h2>Memberships</h2>
+<table>
+<tr><th>Group</th><th>Type</th><th>Via</th><th>Until</th><th>Reason</th><th>Ticket</th><th>Approved By</th><th>Drift</th></tr>
+{{range .Memberships}}<tr><td><a href="../groups/{{page .Group}}">{{.Group}}</a></td><td>{{.Type}}</td><td>{{.Via}}</td><td>{{.Until}}</td><td>{{.Reason}}</td><td>{{.Ticket}}</td><td>{{.ApprovedBy}}</td><td>{{.Drift}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+
+{{define "group"}}{{template "header" .CN}}<p><a href="../index.html">Index</a></p>
+<h1>{{.CN}}</h1>
+<table>
+<tr><th>DN</th><td>{{.DN}}</td></tr>
+<tr><th>Type</th><td>{{.Type}}</td></tr>
+<tr><th>Description</th><td>{{.Description}}</td></tr>
+<tr><th>Owners</th><td>{{range .Owners}}<a href="../users/{{page .}}">{{.}}</a><br>{{end}}</td></tr>
+{{if .Drift}}<tr><th>Drift</th><td>{{.Drift}}</td></tr>
+{{end}}</table>
+<h2>Members ({{.MemberCount}})</h2>
+<table>
+<tr><th>Username</th><th>Via</th><th>Until</th><th>Drift</th></tr>
+{{range .Members}}<tr><td><a href="../users/{{page .Username}}">{{.Username}}</a></td><td>{{.Via}}</td><td>{{.Until}}</td><td>{{.Drift}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+`))
+
+// writeAuditHTML writes a static HTML report with an index page and one page per user and group into dir
+func writeAuditHTML(dir string, r *auditReport) error {
+	var (
+		err   error
+		user  *auditUser
+		group *auditGroup
+		sub   string
+	)
+
+	for _, sub = range []string{"users", "groups"} {
+		if err = os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return fmt.Errorf("failed to create audit directory: %s", err.Error())
+		}
+	}
+
+	if err = writeAuditPage(filepath.Join(dir, "index.html"), "index", r); err != nil {
+		return err
+	}
+
+	for _, user = range r.Users {
+		if err = writeAuditPage(filepath.Join(dir, "users", auditPageName(user.Username)), "user", user); err != nil {
+			return err
+		}
+	}
+
+	for _, group = range r.Groups {
+		if err = writeAuditPage(filepath.Join(dir, "groups", auditPageName(group.DN)), "group", group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAuditPage renders a single page of the HTML report
+func writeAuditPage(path string, name string, data interface{}) error {
+	var (
+		err  error
+		file *os.File
+	)
+
+	if file, err = os.Create(path); err != nil {
+		return fmt.Errorf("failed to create audit page: %s", err.Error())
+	}
+	defer file.Close()
+
+	if err = auditHTMLTemplates.ExecuteTemplate(file, name, data); err != nil {
+		return fmt.Errorf("failed to write audit page %s: %s", path, err.Error())
+	}
+
+	return nil
+}
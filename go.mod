@@ -1,4 +1,4 @@
-module main
+module monban
 
 go 1.13
 
@@ -9,5 +9,6 @@ require (
 	github.com/urfave/cli v1.22.2 // indirect
 	github.com/urfave/cli/v2 v2.0.0
 	golang.org/x/crypto v0.0.0-20191206172530-e9b2fee46413
+	golang.org/x/text v0.3.6
 	gopkg.in/yaml.v3 v3.0.0-20191120175047-4206685974f2
 )
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// groupMemberPrefix marks members of group files that reference another group instead of a user
+// the group is given by its path relative to group_dir, e.g. group:servers/prod/default
+const groupMemberPrefix = "group:"
+
+// groupMembership describes a group a user is member of
+type groupMembership struct {
+	dn string
+	// via is the nested group the membership is inherited from, empty for direct memberships
+	via string
+}
+
+// resolveGroupMembers sets the member DNs of all local groups
+// paths maps the path of every group file relative to group_dir to the DN of its group
+func resolveGroupMembers(paths map[string]string) {
+	var (
+		dn     string
+		group  groupOfNames
+		users  map[string]string
+		member string
+		target string
+		ok     bool
+		i      int
+	)
+
+	// usernames are unique, which is verified when reading people files
+	users = make(map[string]string)
+	for dn = range localPeople {
+		for i = range localPeople[dn].Objects {
+			users[*localPeople[dn].Objects[i].UID] = localPeople[dn].Objects[i].dn
+		}
+	}
+
+	for dn, group = range localGroups {
+		group.memberDNs = nil
+
+		for i = range group.Members {
+			member = group.Members[i].Member
+
+			if !strings.HasPrefix(member, groupMemberPrefix) {
+				// unknown users have already been reported; expired memberships and the ones not started yet are
+				// treated as absent
+				if target, ok = users[member]; ok && group.Members[i].active(now) {
+					group.memberDNs = append(group.memberDNs, target)
+				}
+				continue
+			}
+
+			if target, ok = paths[filepath.Clean(strings.TrimPrefix(member, groupMemberPrefix))]; !ok {
+				addConfigError(group.file, yamlSequenceItem(yamlMappingValue(group.node, "members"), i),
+					"member group %s doesn't exist", strings.TrimPrefix(member, groupMemberPrefix))
+				continue
+			}
+
+			if _, ok = localUnixGroups[target]; ok {
+				addConfigError(group.file, yamlSequenceItem(yamlMappingValue(group.node, "members"), i),
+					"member group %s is of type posixGroup and can't be a member of other groups",
+					strings.TrimPrefix(member, groupMemberPrefix))
+				continue
+			}
+
+			if target == dn {
+				addConfigError(group.file, yamlSequenceItem(yamlMappingValue(group.node, "members"), i),
+					"group can't be a member of itself")
+				continue
+			}
+
+			if group.Members[i].active(now) {
+				group.memberDNs = append(group.memberDNs, target)
+			}
+		}
+
+		expandMemberRules(&group)
+
+		localGroups[dn] = group
+	}
+}
+
+// checkGroupCycles reports nested groups that are (indirectly) members of themselves
+// every cycle is reported once at the group it was found in first (in alphabetical order)
+func checkGroupCycles() {
+	var (
+		dns     []string
+		dn      string
+		state   map[string]int
+		visit   func(dn string, path []string)
+		visited = 1
+		done    = 2
+	)
+
+	state = make(map[string]int)
+
+	visit = func(dn string, path []string) {
+		var (
+			member string
+			ok     bool
+		)
+
+		state[dn] = visited
+		path = append(path, dn)
+
+		for _, member = range localGroups[dn].memberDNs {
+			if _, ok = localGroups[member]; !ok {
+				// users
+				continue
+			}
+
+			switch state[member] {
+			case visited:
+				addConfigError(localGroups[dn].file, yamlMappingValue(localGroups[dn].node, "members"),
+					"nested groups form a cycle: %s -> %s", strings.Join(path[indexOf(path, member):], " -> "), member)
+
+			case 0:
+				visit(member, path)
+			}
+		}
+
+		state[dn] = done
+	}
+
+	for dn = range localGroups {
+		dns = append(dns, dn)
+	}
+	sort.Strings(dns)
+
+	for _, dn = range dns {
+		if state[dn] == 0 {
+			visit(dn, nil)
+		}
+	}
+}
+
+// checkGroupOwners verifies that all owners of a group exist as user objects and sets their DNs
+// node is the sequence node of owners and only used for error positions
+func checkGroupOwners(file string, node *yaml.Node, group *groupOfNames) {
+	var (
+		i    int
+		user *posixAccount
+	)
+
+	group.ownerDNs = nil
+
+	for i = range group.Owners {
+		if indexOf(group.Owners, group.Owners[i]) < i {
+			addConfigError(file, yamlSequenceItem(node, i), "duplicated owner %s", group.Owners[i])
+			continue
+		}
+
+		if user = findUser(group.Owners[i]); user == nil {
+			addConfigError(file, yamlSequenceItem(node, i), "owner uid %s doesn't exist as user object", group.Owners[i])
+			continue
+		}
+
+		group.ownerDNs = append(group.ownerDNs, user.dn)
+	}
+}
+
+// groupIndex maps member DNs (lower case) or usernames to the DNs of all groups they are direct members of
+// it is built once so memberships of users can be looked up without scanning every group
+type groupIndex map[string][]string
+
+// newGroupIndex creates the index of the member DNs of the given groups, group DNs are sorted for every member
+func newGroupIndex(groups map[string]groupOfNames) groupIndex {
+	var (
+		index  groupIndex
+		dn     string
+		member string
+	)
+
+	index = make(groupIndex)
+
+	for dn = range groups {
+		for _, member = range groups[dn].memberDNs {
+			index[strings.ToLower(member)] = append(index[strings.ToLower(member)], dn)
+		}
+	}
+
+	index.sort()
+
+	return index
+}
+
+// newUnixGroupIndex creates the index of the memberUid values of the given unix groups
+func newUnixGroupIndex(groups map[string]posixGroup) groupIndex {
+	var (
+		index groupIndex
+		dn    string
+		uid   string
+	)
+
+	index = make(groupIndex)
+
+	for dn = range groups {
+		for _, uid = range groups[dn].memberUIDs {
+			index[uid] = append(index[uid], dn)
+		}
+	}
+
+	index.sort()
+
+	return index
+}
+
+// sort orders the group DNs of every member
+func (g groupIndex) sort() {
+	var member string
+
+	for member = range g {
+		sort.Strings(g[member])
+	}
+}
+
+// effectiveGroups returns all groups of an index a DN is member of, including memberships inherited through nested
+// groups; the list is sorted with direct memberships first
+func effectiveGroups(index groupIndex, memberDN string) []groupMembership {
+	var (
+		memberships []groupMembership
+		seen        map[string]bool
+		queue       []groupMembership
+		current     groupMembership
+		dn          string
+		via         string
+	)
+
+	seen = make(map[string]bool)
+	queue = []groupMembership{{dn: memberDN}}
+
+	// breadth first, so the shortest path to every group is shown
+	for len(queue) > 0 {
+		current = queue[0]
+		queue = queue[1:]
+
+		for _, dn = range index[strings.ToLower(current.dn)] {
+			if seen[dn] {
+				continue
+			}
+
+			seen[dn] = true
+
+			// the first nested group on the way is the interesting one for audits
+			via = current.via
+			if current.dn != memberDN && via == "" {
+				via = current.dn
+			}
+
+			memberships = append(memberships, groupMembership{dn: dn, via: via})
+			queue = append(queue, groupMembership{dn: dn, via: via})
+		}
+	}
+
+	return memberships
+}
+
+// containsFold returns true if list contains s, ignoring case as DNs are case insensitive
+func containsFold(list []string, s string) bool {
+	var item string
+
+	for _, item = range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equalFold returns true if a and b contain the same values regardless of their order and case
+func equalFold(a []string, b []string) bool {
+	var item string
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, item = range a {
+		if !containsFold(b, item) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexOf returns the index of s within list or -1 if list doesn't contain s
+func indexOf(list []string, s string) int {
+	var i int
+
+	for i = range list {
+		if list[i] == s {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -34,31 +34,24 @@ func ldapConnect() (*ldap.Conn, error) {
 // ldapLoadPeople loads all people objects from LDAP
 func ldapLoadPeople() error {
 	var (
-		err       error
-		sr        *ldap.SearchResult
-		user      *posixAccount
-		group     *posixGroup
-		ou        *organizationalUnit
-		i         int
-		j         int
-		tmpPeople posixGroup
-		// class will be posixAccount or posixGroup
-		class string
+		err error
+		sr  *ldap.SearchResult
 	)
 
 	glg.Infof("reading people objects from LDAP")
 
 	// get a list of all existing objects within the peopleDN
 	sr, err = ldapCon.Search(&ldap.SearchRequest{
-		peopleDN,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0,
-		0,
-		false,
-		"(objectClass=*)",
-		nil,
-		nil,
+		BaseDN:       peopleDN,
+		Scope:        ldap.ScopeWholeSubtree,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    0,
+		TimeLimit:    0,
+		TypesOnly:    false,
+		Filter:       "(objectClass=*)",
+		// pwdAccountLockedTime (nsAccountLock with 389ds) is operational and only returned when asked for
+		Attributes: []string{"*", ldapAttribute("pwdAccountLockedTime")},
+		Controls:   nil,
 	})
 	if err != nil {
 		// check if error is only group being missing
@@ -69,88 +62,137 @@ func ldapLoadPeople() error {
 		return err
 	}
 
+	return ldapReadPeople(sr.Entries)
+}
+
+// ldapReadPeople adds the people objects of a search below peopleDN to ldapPeople and ldapOUs
+func ldapReadPeople(entries []*ldap.Entry) error {
+	var (
+		user      *posixAccount
+		group     *posixGroup
+		ou        *organizationalUnit
+		i         int
+		j         int
+		tmpPeople posixGroup
+		// class will be posixAccount or posixGroup
+		class string
+		name  string
+		ok    bool
+	)
+
 	// go through all user objects
 	// NOTE: this assumes the result is ordered in a way that children don't appear before its parent
-	for i = range sr.Entries {
-		if sr.Entries[i].DN == peopleDN {
+	for i = range entries {
+		if entries[i].DN == peopleDN {
 			// skip peopleDN object
 			continue
 		}
 
+		// groups of group_dir are loaded by ldapReadGroups when people_rdn and group_rdn overlap
+		if _, ok = localUnixGroups[entries[i].DN]; ok {
+			continue
+		}
+
+		if _, ok = localGroups[entries[i].DN]; ok {
+			continue
+		}
+
 		user = new(posixAccount)
 		group = new(posixGroup)
 		ou = new(organizationalUnit)
 
 		// set DN for all objects as it is unknown which object it is
-		group.dn = sr.Entries[i].DN
-		user.dn = sr.Entries[i].DN
-		ou.dn = sr.Entries[i].DN
+		group.dn = entries[i].DN
+		user.dn = entries[i].DN
+		ou.dn = entries[i].DN
 
 		// go through all attributes
-		for j = range sr.Entries[i].Attributes {
+		for j = range entries[i].Attributes {
 			// assuming that there is only one value for all attributes
-			switch sr.Entries[i].Attributes[j].Name {
+			switch entries[i].Attributes[j].Name {
 
 			// there is no guarantee the class attribute is the first attribute, thus until known both structs are filled
 			// with information
 			case "objectClass":
 				// there is more than one objectClass
-				for _, class = range sr.Entries[i].Attributes[j].Values {
+				for _, class = range entries[i].Attributes[j].Values {
 					if class == "posixAccount" || class == "posixGroup" || class == "organizationalUnit" {
 						break
 					}
 				}
 
 			case "ou":
-				ou.cn = sr.Entries[i].Attributes[j].Values[0]
+				ou.cn = entries[i].Attributes[j].Values[0]
 
 			case "cn":
-				group.CN = sr.Entries[i].Attributes[j].Values[0]
-				user.UID = &sr.Entries[i].Attributes[j].Values[0]
+				group.CN = entries[i].Attributes[j].Values[0]
+				user.UID = &entries[i].Attributes[j].Values[0]
 
-			case "description":
-				group.Description = sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("description"):
+				group.Description = entries[i].Attributes[j].Values[0]
 
-			case "gidNumber":
+			case ldapAttribute("memberUid"):
+				group.memberUIDs = entries[i].Attributes[j].Values
+
+			case ldapAttribute("gidNumber"):
 				user.GIDNumber = new(int)
-				*user.GIDNumber, _ = strconv.Atoi(sr.Entries[i].Attributes[j].Values[0])
+				*user.GIDNumber, _ = strconv.Atoi(entries[i].Attributes[j].Values[0])
 
 				group.GIDNumber = new(int)
-				*group.GIDNumber, _ = strconv.Atoi(sr.Entries[i].Attributes[j].Values[0])
+				*group.GIDNumber, _ = strconv.Atoi(entries[i].Attributes[j].Values[0])
 
-			case "homeDirectory":
-				user.HomeDir = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("homeDirectory"):
+				user.HomeDir = &entries[i].Attributes[j].Values[0]
 
-			case "sn":
-				user.Surname = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("sn"):
+				user.Surname = &entries[i].Attributes[j].Values[0]
 
-			case "uidNumber":
+			case ldapAttribute("uidNumber"):
 				user.UIDNumber = new(int)
-				*user.UIDNumber, _ = strconv.Atoi(sr.Entries[i].Attributes[j].Values[0])
+				*user.UIDNumber, _ = strconv.Atoi(entries[i].Attributes[j].Values[0])
 
 				// determine the highest used UIDNumber
 				if *user.UIDNumber > latestUID {
 					latestUID = *user.UIDNumber
 				}
 
-			case "displayName":
-				user.DisplayName = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("displayName"):
+				user.DisplayName = &entries[i].Attributes[j].Values[0]
+
+			case ldapAttribute("givenName"):
+				user.GivenName = &entries[i].Attributes[j].Values[0]
 
-			case "givenName":
-				user.GivenName = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("loginShell"):
+				user.LoginShell = &entries[i].Attributes[j].Values[0]
 
-			case "loginShell":
-				user.LoginShell = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("mail"):
+				user.Mail = &entries[i].Attributes[j].Values[0]
 
-			case "mail":
-				user.Mail = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("sshPublicKey"):
+				user.SSHPublicKey = &entries[i].Attributes[j].Values[0]
 
-			case "sshPublicKey":
-				user.SSHPublicKey = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("userPassword"):
+				user.UserPassword = &entries[i].Attributes[j].Values[0]
+				user.Disabled = new(bool)
+				*user.Disabled = strings.HasPrefix(*user.UserPassword, lockedPasswordPrefix)
 
-			case "userPassword":
-				user.UserPassword = &sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("shadowExpire"):
+				user.Expires = parseShadowExpire(entries[i].Attributes[j].Values[0])
 
+			case ldapAttribute("pwdAccountLockedTime"):
+				// lockouts after failed logins are managed by the password policy, only permanent locks are compared
+				user.Locked = new(bool)
+				*user.Locked = strings.EqualFold(entries[i].Attributes[j].Values[0], accountLockValue())
+
+			default:
+				// additional attributes declared in schema config
+				if name = schemaAttributeName(objectTypePosixAccount, entries[i].Attributes[j].Name); name != "" {
+					if user.Attributes == nil {
+						user.Attributes = make(map[string]attributeValues)
+					}
+
+					user.Attributes[name] = entries[i].Attributes[j].Values
+				}
 			}
 		}
 
@@ -186,27 +228,21 @@ func ldapLoadPeople() error {
 // ldapLoadGroups loads all group objects from LDAP
 func ldapLoadGroups() error {
 	var (
-		err   error
-		sr    *ldap.SearchResult
-		i     int
-		j     int
-		k     int
-		group *groupOfNames
-		ou    *organizationalUnit
-		class string
+		err error
+		sr  *ldap.SearchResult
 	)
 
 	// get a list of all existing objects within the groupDN
 	sr, err = ldapCon.Search(&ldap.SearchRequest{
-		groupDN,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0,
-		0,
-		false,
-		"(objectClass=*)",
-		nil,
-		nil,
+		BaseDN:       groupDN,
+		Scope:        ldap.ScopeWholeSubtree,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    0,
+		TimeLimit:    0,
+		TypesOnly:    false,
+		Filter:       "(objectClass=*)",
+		Attributes:   nil,
+		Controls:     nil,
 	})
 	if err != nil {
 		// check if error is only group being missing
@@ -216,62 +252,108 @@ func ldapLoadGroups() error {
 		return err
 	}
 
+	return ldapReadGroups(sr.Entries)
+}
+
+// ldapReadGroups adds the group objects of a search below groupDN to ldapGroups, ldapUnixGroups and ldapOUs
+func ldapReadGroups(entries []*ldap.Entry) error {
+	var (
+		i          int
+		j          int
+		k          int
+		group      *groupOfNames
+		ou         *organizationalUnit
+		class      string
+		name       string
+		classes    []string
+		memberUIDs []string
+	)
+
 	// go through all user objects
 	// NOTE: this assumes the result is ordered in a way that children don't appear before its parent
-	for i = range sr.Entries {
-		if sr.Entries[i].DN == groupDN {
+	for i = range entries {
+		if entries[i].DN == groupDN {
 			// skip groupDN object
 			continue
 		}
 
 		group = new(groupOfNames)
 		ou = new(organizationalUnit)
+		classes = nil
+		memberUIDs = nil
 
 		// set DN for all objects as it is unknown which object it is
-		group.dn = sr.Entries[i].DN
-		ou.dn = sr.Entries[i].DN
+		group.dn = entries[i].DN
+		ou.dn = entries[i].DN
 
 		// go through all attributes
-		for j = range sr.Entries[i].Attributes {
+		for j = range entries[i].Attributes {
 			// assuming that there is only one value for all attributes
-			switch sr.Entries[i].Attributes[j].Name {
+			switch entries[i].Attributes[j].Name {
 
 			// there is no guarantee the class attribute is the first attribute, thus until known both structs are filled
 			// with information
 			case "objectClass":
 				// there is more than one objectClass
-				for _, class = range sr.Entries[i].Attributes[j].Values {
-					if class == "groupOfNames" || class == "organizationalUnit" {
-						break
-					}
-				}
+				classes = entries[i].Attributes[j].Values
 
 			case "ou":
-				ou.cn = sr.Entries[i].Attributes[j].Values[0]
+				ou.cn = entries[i].Attributes[j].Values[0]
 
 			case "cn":
-				group.CN = sr.Entries[i].Attributes[j].Values[0]
+				group.CN = entries[i].Attributes[j].Values[0]
 
-			case "description":
-				group.Description = sr.Entries[i].Attributes[j].Values[0]
+			case ldapAttribute("memberUid"):
+				memberUIDs = entries[i].Attributes[j].Values
 
-			case "member":
-				// rewrite members to UIDs only as this the format used in config files
+			case ldapAttribute("description"):
+				group.Description = entries[i].Attributes[j].Values[0]
 
-				for k = range sr.Entries[i].Attributes[j].Values {
+			case ldapAttribute("gidNumber"):
+				group.GIDNumber = new(int)
+				*group.GIDNumber, _ = strconv.Atoi(entries[i].Attributes[j].Values[0])
+
+			case ldapAttribute("owner"):
+				group.ownerDNs = entries[i].Attributes[j].Values
 
-					if sr.Entries[i].Attributes[j].Values[k] == "uid=MonbanDummyMember" {
+			case "member":
+				// members are compared by DN as they can be users as well as nested groups
+				for k = range entries[i].Attributes[j].Values {
+
+					if entries[i].Attributes[j].Values[k] == "uid=MonbanDummyMember" {
 						// ignoring dummy member
 						continue
 					}
 
-					group.Members = append(group.Members, strings.Split(sr.Entries[i].Attributes[j].Values[k], ",")[0][4:])
+					group.memberDNs = append(group.memberDNs, entries[i].Attributes[j].Values[k])
+				}
+
+			default:
+				// additional attributes declared in schema config
+				if name = schemaAttributeName(objectTypeGroupOfNames, entries[i].Attributes[j].Name); name != "" {
+					if group.Attributes == nil {
+						group.Attributes = make(map[string]attributeValues)
+					}
+
+					group.Attributes[name] = entries[i].Attributes[j].Values
 				}
 			}
 		}
 
-		// check if object was a posixAccount or posixGroup
+		class = ldapGroupClass(group.dn, classes, group.GIDNumber != nil || len(memberUIDs) > 0)
+
+		// check if object was a groupOfNames or posixGroup
 		switch class {
+		case "posixGroup":
+			ldapUnixGroups[group.dn] = posixGroup{
+				dn:          group.dn,
+				CN:          group.CN,
+				GIDNumber:   group.GIDNumber,
+				Description: group.Description,
+				memberUIDs:  memberUIDs,
+			}
+			glg.Debugf("found ldap posixGroup %s", group.dn)
+
 		case "groupOfNames":
 			// add to global list of LDAP groups
 			// NOTE: this is a workaround as append on struct member within a map is not supported
@@ -279,15 +361,16 @@ func ldapLoadGroups() error {
 			ldapGroups[group.dn] = *group
 			glg.Debugf("found ldap groupOfNames %s", group.dn)
 
-			for i = range group.Members {
-				if group.Members[i] == "uid=MonbanDummyMember" {
-					// ignore dummy member
-					continue
-				}
-				glg.Debugf("found member %s", group.Members[i])
+			for k = range group.memberDNs {
+				glg.Debugf("found member %s", group.memberDNs[k])
 			}
 
 		case "organizationalUnit":
+			// OUs were loaded by ldapReadPeople already when people_rdn and group_rdn overlap
+			if ldapOUExists(ou.dn) {
+				continue
+			}
+
 			ldapOUs = append(ldapOUs, ou)
 			glg.Debugf("found ldap intermediate OU %s", ou.dn)
 		}
@@ -297,6 +380,73 @@ func ldapLoadGroups() error {
 	return nil
 }
 
+// ldapGroupClass returns the object class an object below groupDN is handled as (organizationalUnit, groupOfNames or
+// posixGroup) or an empty string for unknown objects
+// groups that are groupOfNames and posixGroup at the same time (rfc2307bis) are unix groups if they are defined as such
+// in group_dir and groupOfNames with gid_number if defined as groupOfNames; unknown groups are unix groups if they
+// carry posixGroup data (gidNumber or memberUid)
+// posixGroups of people files are left to ldapReadPeople, which matters when people_rdn and group_rdn overlap (both
+// default to root_dn): unknown posixGroups below peopleDN are treated as people groups then
+func ldapGroupClass(dn string, classes []string, posixData bool) string {
+	var ok bool
+
+	switch {
+	case containsString(classes, "organizationalUnit"):
+		return "organizationalUnit"
+
+	case containsString(classes, "groupOfNames") && containsString(classes, "posixGroup"):
+		if _, ok = localUnixGroups[dn]; ok {
+			return "posixGroup"
+		}
+
+		if _, ok = localGroups[dn]; ok || !posixData {
+			return "groupOfNames"
+		}
+
+		if isPeopleGroup(dn) {
+			return ""
+		}
+
+		return "posixGroup"
+
+	case containsString(classes, "groupOfNames"):
+		return "groupOfNames"
+
+	case containsString(classes, "posixGroup"):
+		if _, ok = localUnixGroups[dn]; !ok && isPeopleGroup(dn) {
+			return ""
+		}
+
+		return "posixGroup"
+	}
+
+	return ""
+}
+
+// ldapOUExists returns true if an OU with the given DN was loaded from LDAP already
+func ldapOUExists(dn string) bool {
+	var i int
+
+	for i = range ldapOUs {
+		if ldapOUs[i].dn == dn {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPeopleGroup returns true if the posixGroup with the given DN is defined by a people file or located below peopleDN
+func isPeopleGroup(dn string) bool {
+	var ok bool
+
+	if _, ok = localPeople[dn]; ok {
+		return true
+	}
+
+	return strings.HasSuffix(strings.ToLower(dn), ","+strings.ToLower(peopleDN))
+}
+
 // ldapDeleteGroupOfNamesMember deletes a given user from a LDAP group
 func ldapDeleteGroupOfNamesMember(group string, user string) error {
 	var (
@@ -311,58 +461,42 @@ func ldapDeleteGroupOfNamesMember(group string, user string) error {
 	return ldapCon.Modify(modify)
 }
 
-// ldapDeletePosixAccount delets a given posixAccount object from LDAP
-func ldapDeletePosixAccount(dn string) error {
+// ldapDeletePosixGroupMember deletes a given username from the memberUid values of a LDAP posixGroup
+func ldapDeletePosixGroupMember(group string, uid string) error {
 	var (
-		err         error
-		modify      *ldap.ModifyRequest
-		dnFragments []string
+		modify *ldap.ModifyRequest
 	)
 
-	glg.Debugf("deleting posixAccount %s", dn)
-
-	// delete user object
-	if err = ldapCon.Del(&ldap.DelRequest{
-		DN:       dn,
-		Controls: nil,
-	}); err != nil {
-		return err
-	}
+	glg.Debugf("deleting posixGroup member in %s", group)
 
-	// delete memberUid reference in UnixGroup
-	dnFragments = strings.Split(dn, ",")
+	modify = ldap.NewModifyRequest(group, nil)
+	modify.Delete(ldapAttribute("memberUid"), []string{uid})
 
-	glg.Debugf("deleting posixGroup member in %s", strings.Join(dnFragments[1:], ","))
-	modify = ldap.NewModifyRequest(strings.Join(dnFragments[1:], ","), nil)
+	return ldapCon.Modify(modify)
+}
 
-	modify.Delete("memberUid", []string{dnFragments[0][4:]})
+// ldapDeletePosixAccount delets a given posixAccount object from LDAP
+func ldapDeletePosixAccount(dn string) error {
+	glg.Debugf("deleting posixAccount %s", dn)
 
-	return ldapCon.Modify(modify)
+	// memberUid references are removed by separate posixGroup member tasks
+	return ldapCon.Del(&ldap.DelRequest{
+		DN:       dn,
+		Controls: nil,
+	})
 }
 
 // ldapCreatePosixAccount creates a new posixAccount object in LDAP
 func ldapCreatePosixAccount(user *posixAccount) error {
 	var (
-		err      error
-		add      *ldap.AddRequest
-		modify   *ldap.ModifyRequest
-		dnSplits []string
+		add *ldap.AddRequest
 	)
 
 	glg.Debugf("creating posixAccount %s", user.dn)
 
 	add = ldap.NewAddRequest(user.dn, nil)
 
-	add.Attribute("objectClass", []string{
-		"inetOrgPerson",
-		"ldapPublicKey",
-		"organizationalPerson",
-		"person",
-		"posixAccount",
-		"shadowAccount",
-		"top"})
-
-	dnSplits = strings.Split(user.dn, ",")
+	add.Attribute("objectClass", objectClasses("posixAccount"))
 
 	// UIDNumber should be set if generateUID is false
 	if config.GenerateUID {
@@ -378,35 +512,51 @@ func ldapCreatePosixAccount(user *posixAccount) error {
 
 	// strings
 	add.Attribute("cn", []string{*user.UID})
-	add.Attribute("gidNumber", []string{strconv.Itoa(*user.GIDNumber)})
-	add.Attribute("uidNumber", []string{strconv.Itoa(*user.UIDNumber)})
-	add.Attribute("homeDirectory", []string{*user.HomeDir})
-	add.Attribute("sn", []string{*user.Surname})
+	add.Attribute(ldapAttribute("gidNumber"), []string{strconv.Itoa(*user.GIDNumber)})
+	add.Attribute(ldapAttribute("uidNumber"), []string{strconv.Itoa(*user.UIDNumber)})
+	add.Attribute(ldapAttribute("homeDirectory"), []string{*user.HomeDir})
+	add.Attribute(ldapAttribute("sn"), []string{*user.Surname})
 	add.Attribute("uid", []string{*user.UID})
-	add.Attribute("displayName", []string{*user.DisplayName})
-	add.Attribute("givenName", []string{*user.GivenName})
-	add.Attribute("loginShell", []string{*user.LoginShell})
-	add.Attribute("mail", []string{*user.Mail})
-	add.Attribute("userPassword", []string{*user.UserPassword})
 
-	if *config.EnableSSHPublicKeys {
-		if user.SSHPublicKey != nil {
-			add.Attribute("sshPublicKey", []string{*user.SSHPublicKey})
-		}
+	// attributes with policy ignore are left to others, e.g. self-service
+	if user.created("display_name") {
+		add.Attribute(ldapAttribute("displayName"), []string{*user.DisplayName})
 	}
 
-	if err = ldapCon.Add(add); err != nil {
-		return err
+	if user.created("given_name") {
+		add.Attribute(ldapAttribute("givenName"), []string{*user.GivenName})
+	}
+
+	if user.created("login_shell") {
+		add.Attribute(ldapAttribute("loginShell"), []string{*user.LoginShell})
+	}
+
+	if user.created("mail") {
+		add.Attribute(ldapAttribute("mail"), []string{*user.Mail})
 	}
 
-	// create memberUid reference in UnixGroup
-	modify = ldap.NewModifyRequest(strings.Join(dnSplits[1:], ","), nil)
+	if user.created("user_password") {
+		add.Attribute(ldapAttribute("userPassword"), []string{ldapPassword(user)})
+	}
 
-	glg.Debugf("adding posixGroup member in %s", strings.Join(dnSplits[1:], ","))
+	if user.Expires != nil {
+		add.Attribute(ldapAttribute("shadowExpire"), []string{shadowExpireValue(*user.Expires)})
+	}
 
-	modify.Add("memberUid", []string{*user.UID})
+	if user.isLocked() {
+		add.Attribute(ldapAttribute("pwdAccountLockedTime"), []string{accountLockValue()})
+	}
 
-	return ldapCon.Modify(modify)
+	if *config.EnableSSHPublicKeys && user.created("ssh_public_key") {
+		if user.SSHPublicKey != nil {
+			add.Attribute(ldapAttribute("sshPublicKey"), []string{*user.SSHPublicKey})
+		}
+	}
+
+	addAttributes(add, user.createdAttributes(user.Attributes))
+
+	// memberUid references are added by separate posixGroup member tasks
+	return ldapCon.Add(add)
 }
 
 // ldapUpdatePosixAccount updates an existing posixAccount object in LDAP
@@ -424,47 +574,67 @@ func ldapUpdatePosixAccount(user *posixAccount) error {
 	}
 
 	if user.GIDNumber != nil {
-		modify.Replace("gidNumber", []string{strconv.Itoa(*user.GIDNumber)})
+		modify.Replace(ldapAttribute("gidNumber"), []string{strconv.Itoa(*user.GIDNumber)})
 	}
 
 	if user.UIDNumber != nil {
-		modify.Replace("uidNumber", []string{strconv.Itoa(*user.UIDNumber)})
+		modify.Replace(ldapAttribute("uidNumber"), []string{strconv.Itoa(*user.UIDNumber)})
+	}
+
+	if user.HomeDir != nil && user.managed("home_dir") {
+		modify.Replace(ldapAttribute("homeDirectory"), []string{*user.HomeDir})
+	}
+
+	if user.Surname != nil && user.managed("surname") {
+		modify.Replace(ldapAttribute("sn"), []string{*user.Surname})
 	}
 
-	if user.HomeDir != nil {
-		modify.Replace("homeDirectory", []string{*user.HomeDir})
+	if user.DisplayName != nil && user.managed("display_name") {
+		modify.Replace(ldapAttribute("displayName"), []string{*user.DisplayName})
 	}
 
-	if user.Surname != nil {
-		modify.Replace("sn", []string{*user.Surname})
+	if user.GivenName != nil && user.managed("given_name") {
+		modify.Replace(ldapAttribute("givenName"), []string{*user.GivenName})
 	}
 
-	if user.DisplayName != nil {
-		modify.Replace("displayName", []string{*user.DisplayName})
+	if user.LoginShell != nil && user.managed("login_shell") {
+		modify.Replace(ldapAttribute("loginShell"), []string{*user.LoginShell})
 	}
 
-	if user.GivenName != nil {
-		modify.Replace("givenName", []string{*user.GivenName})
+	if user.Mail != nil && user.managed("mail") {
+		modify.Replace(ldapAttribute("mail"), []string{*user.Mail})
 	}
 
-	if user.LoginShell != nil {
-		modify.Replace("loginShell", []string{*user.LoginShell})
+	// passwords that aren't managed are still locked and unlocked when the account is disabled or enabled
+	if user.UserPassword != nil && (user.managed("user_password") || user.Disabled != nil) {
+		modify.Replace(ldapAttribute("userPassword"), []string{*user.UserPassword})
 	}
 
-	if user.Mail != nil {
-		modify.Replace("mail", []string{*user.Mail})
+	// zero time means expiry was removed from the config
+	if user.Expires != nil {
+		if user.Expires.IsZero() {
+			modify.Delete(ldapAttribute("shadowExpire"), nil)
+		} else {
+			modify.Replace(ldapAttribute("shadowExpire"), []string{shadowExpireValue(*user.Expires)})
+		}
 	}
 
-	if user.UserPassword != nil {
-		modify.Replace("userPassword", []string{*user.UserPassword})
+	if user.Locked != nil {
+		if *user.Locked {
+			modify.Replace(ldapAttribute("pwdAccountLockedTime"), []string{accountLockValue()})
+		} else {
+			modify.Delete(ldapAttribute("pwdAccountLockedTime"), nil)
+		}
 	}
 
-	if *config.EnableSSHPublicKeys {
+	if *config.EnableSSHPublicKeys && user.managed("ssh_public_key") {
 		if user.SSHPublicKey != nil {
-			modify.Replace("sshPublicKey", []string{*user.SSHPublicKey})
+			modify.Replace(ldapAttribute("sshPublicKey"), []string{*user.SSHPublicKey})
 		}
 	}
 
+	replaceAttributes(modify, user.managedAttributes(user.Attributes))
+
 	return ldapCon.Modify(modify)
 }
 
@@ -483,6 +653,20 @@ func ldapAddGroupOfNamesMember(group string, user string) error {
 	return ldapCon.Modify(modify)
 }
 
+// ldapAddPosixGroupMember adds a given username to the memberUid values of a LDAP posixGroup
+func ldapAddPosixGroupMember(group string, uid string) error {
+	var (
+		modify *ldap.ModifyRequest
+	)
+
+	glg.Debugf("adding posixGroup member in %s", group)
+
+	modify = ldap.NewModifyRequest(group, nil)
+	modify.Add(ldapAttribute("memberUid"), []string{uid})
+
+	return ldapCon.Modify(modify)
+}
+
 // ldapCreatePosixGroup creates a new posixGroup on LDAP target
 func ldapCreatePosixGroup(group posixGroup) error {
 	var (
@@ -493,14 +677,17 @@ func ldapCreatePosixGroup(group posixGroup) error {
 
 	add = ldap.NewAddRequest(group.dn, nil)
 
-	add.Attribute("objectClass", []string{
-		"posixGroup",
-		"top"})
+	add.Attribute("objectClass", objectClasses("posixGroup"))
 
 	// strings
 	add.Attribute("cn", []string{group.CN})
-	add.Attribute("gidNumber", []string{strconv.Itoa(*group.GIDNumber)})
-	add.Attribute("description", []string{group.Description})
+	add.Attribute(ldapAttribute("gidNumber"), []string{strconv.Itoa(*group.GIDNumber)})
+	add.Attribute(ldapAttribute("description"), []string{group.Description})
+
+	// groupOfNames requires at least one member (e.g. posixGroup with rfc2307bis)
+	if containsString(objectClasses("posixGroup"), "groupOfNames") {
+		add.Attribute("member", []string{"uid=MonbanDummyMember"})
+	}
 
 	return ldapCon.Add(add)
 }
@@ -516,11 +703,11 @@ func ldapUpdatePosixGroup(group *posixGroup) error {
 	modify = ldap.NewModifyRequest(group.dn, nil)
 
 	if group.GIDNumber != nil {
-		modify.Replace("gidNumber", []string{strconv.Itoa(*group.GIDNumber)})
+		modify.Replace(ldapAttribute("gidNumber"), []string{strconv.Itoa(*group.GIDNumber)})
 	}
 
 	if group.Description != "" {
-		modify.Replace("description", []string{group.Description})
+		modify.Replace(ldapAttribute("description"), []string{group.Description})
 	}
 
 	return ldapCon.Modify(modify)
@@ -547,14 +734,24 @@ func ldapCreateGroupOfNames(group groupOfNames) error {
 
 	add = ldap.NewAddRequest(group.dn, nil)
 
-	add.Attribute("objectClass", []string{
-		"groupOfNames",
-		"top"})
-
 	// strings
 	add.Attribute("cn", []string{group.CN})
 	add.Attribute("member", []string{"uid=MonbanDummyMember"})
-	add.Attribute("description", []string{group.Description})
+	add.Attribute(ldapAttribute("description"), []string{group.Description})
+
+	if len(group.ownerDNs) > 0 {
+		add.Attribute(ldapAttribute("owner"), group.ownerDNs)
+	}
+
+	// with gid_number the group grants unix group membership via its members as well (rfc2307bis)
+	if group.GIDNumber != nil {
+		add.Attribute("objectClass", hybridGroupClasses())
+		add.Attribute(ldapAttribute("gidNumber"), []string{strconv.Itoa(*group.GIDNumber)})
+	} else {
+		add.Attribute("objectClass", objectClasses("groupOfNames"))
+	}
+
+	addAttributes(add, group.Attributes)
 
 	return ldapCon.Add(add)
 }
@@ -570,9 +767,29 @@ func ldapUpdateGroupOfNames(group *groupOfNames) error {
 	modify = ldap.NewModifyRequest(group.dn, nil)
 
 	if group.Description != "" {
-		modify.Replace("description", []string{group.Description})
+		modify.Replace(ldapAttribute("description"), []string{group.Description})
 	}
 
+	switch {
+	case group.addPosixGroup:
+		modify.Add("objectClass", posixGroupExtraClasses())
+		modify.Add(ldapAttribute("gidNumber"), []string{strconv.Itoa(*group.GIDNumber)})
+
+	case group.deletePosixGroup:
+		modify.Delete(ldapAttribute("gidNumber"), nil)
+		modify.Delete("objectClass", posixGroupExtraClasses())
+
+	case group.GIDNumber != nil:
+		modify.Replace(ldapAttribute("gidNumber"), []string{strconv.Itoa(*group.GIDNumber)})
+	}
+
+	if group.updateOwners {
+		// replacing with no values deletes the attribute
+		modify.Replace(ldapAttribute("owner"), group.ownerDNs)
+	}
+
+	replaceAttributes(modify, group.Attributes)
+
 	return ldapCon.Modify(modify)
 }
 
@@ -586,13 +803,11 @@ func ldapCreateOrganisationalUnit(ou *organizationalUnit) error {
 
 	add = ldap.NewAddRequest(ou.dn, nil)
 
-	add.Attribute("objectClass", []string{
-		"organizationalUnit",
-		"top"})
+	add.Attribute("objectClass", objectClasses("organizationalUnit"))
 
 	// strings
 	add.Attribute("ou", []string{ou.cn})
-	add.Attribute("description", []string{ou.description})
+	add.Attribute(ldapAttribute("description"), []string{ou.description})
 
 	return ldapCon.Add(add)
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// TestLDAPGroupClass verifies groups created by Monban are loaded as the same type with every schema profile, which
+// otherwise leads to groups being deleted and created again on every sync
+func TestLDAPGroupClass(t *testing.T) {
+	var (
+		unixDN  = "cn=docker,ou=groups,dc=my-domain,dc=com"
+		groupDN = "cn=developers,ou=groups,dc=my-domain,dc=com"
+		otherDN = "cn=other,ou=groups,dc=my-domain,dc=com"
+		name    string
+		profile *schemaProfile
+		class   string
+	)
+
+	localUnixGroups = map[string]posixGroup{unixDN: posixGroup{dn: unixDN}}
+	localGroups = map[string]groupOfNames{groupDN: groupOfNames{dn: groupDN}}
+	defer func() {
+		localUnixGroups = nil
+		localGroups = nil
+		activeSchema = nil
+	}()
+
+	for name, profile = range schemaProfiles {
+		activeSchema = profile
+
+		// object classes as written by ldapCreatePosixGroup and ldapCreateGroupOfNames
+		if class = ldapGroupClass(unixDN, objectClasses("posixGroup"), true); class != "posixGroup" {
+			t.Errorf("%s: unix group loaded as %s", name, class)
+		}
+
+		if class = ldapGroupClass(groupDN, objectClasses("groupOfNames"), false); class != "groupOfNames" {
+			t.Errorf("%s: groupOfNames loaded as %s", name, class)
+		}
+
+		if class = ldapGroupClass(otherDN, objectClasses("organizationalUnit"), false); class != "organizationalUnit" {
+			t.Errorf("%s: organizationalUnit loaded as %s", name, class)
+		}
+
+		// object classes of hybrid groups (gid_number in a group file)
+		if class = ldapGroupClass(groupDN, hybridGroupClasses(), true); class != "groupOfNames" {
+			t.Errorf("%s: hybrid group loaded as %s", name, class)
+		}
+
+		// groups not defined locally are unix groups if they carry posixGroup data
+		if class = ldapGroupClass(otherDN, objectClasses("posixGroup"), true); class != "posixGroup" {
+			t.Errorf("%s: unknown unix group loaded as %s", name, class)
+		}
+	}
+
+	if class = ldapGroupClass(otherDN, []string{"groupOfNames", "posixGroup", "top"}, false); class != "groupOfNames" {
+		t.Errorf("unknown group without posixGroup data loaded as %s", class)
+	}
+
+	if class = ldapGroupClass(otherDN, []string{"device", "top"}, false); class != "" {
+		t.Errorf("unknown object loaded as %s", class)
+	}
+}
+
+// TestLDAPReadOverlappingDNs verifies people groups and unix groups are only loaded once when people_rdn and group_rdn
+// are the same (both default to root_dn), which otherwise leads to groups of either type being deleted
+func TestLDAPReadOverlappingDNs(t *testing.T) {
+	var (
+		rootDN   = "dc=my-domain,dc=com"
+		devopsDN = "cn=devops," + rootDN
+		dockerDN = "cn=docker," + rootDN
+		gid      = 1001
+		dockerID = 2000
+		user     = testAccount("johndoe")
+		entries  []*ldap.Entry
+		task     *actionTask
+		ok       bool
+		err      error
+	)
+
+	defer setupCompare(time.Now())()
+
+	user.dn = "uid=johndoe," + devopsDN
+	user.GIDNumber = &gid
+
+	peopleDN = rootDN
+	groupDN = rootDN
+	activeSchema = schemaProfiles["openldap-nis"]
+	localPeople = map[string]posixGroup{
+		devopsDN: posixGroup{dn: devopsDN, CN: "devops", GIDNumber: &gid, Objects: []posixAccount{user}},
+	}
+	localUnixGroups = map[string]posixGroup{
+		dockerDN: posixGroup{dn: dockerDN, CN: "docker", GIDNumber: &dockerID, memberUIDs: []string{"johndoe"}},
+	}
+	localGroups = map[string]groupOfNames{}
+	ldapPeople = map[string]posixGroup{}
+	ldapUnixGroups = map[string]posixGroup{}
+	ldapGroups = map[string]groupOfNames{}
+	ldapOUs = nil
+	defer func() {
+		peopleDN = ""
+		groupDN = ""
+		activeSchema = nil
+		localPeople = nil
+		localUnixGroups = nil
+		localGroups = nil
+		ldapPeople = nil
+		ldapUnixGroups = nil
+		ldapGroups = nil
+		ldapOUs = nil
+	}()
+
+	// both searches return the same objects
+	entries = []*ldap.Entry{
+		ldap.NewEntry(rootDN, map[string][]string{"objectClass": []string{"domain", "top"}}),
+		ldap.NewEntry("ou=servers,"+rootDN, map[string][]string{
+			"objectClass": []string{"organizationalUnit", "top"},
+			"ou":          []string{"servers"},
+		}),
+		ldap.NewEntry(devopsDN, map[string][]string{
+			"objectClass": objectClasses("posixGroup"),
+			"cn":          []string{"devops"},
+			"gidNumber":   []string{"1001"},
+			"memberUid":   []string{"johndoe"},
+		}),
+		ldap.NewEntry(user.dn, map[string][]string{
+			"objectClass":   objectClasses("posixAccount"),
+			"cn":            []string{"johndoe"},
+			"uid":           []string{"johndoe"},
+			"gidNumber":     []string{"1001"},
+			"givenName":     []string{*user.GivenName},
+			"sn":            []string{*user.Surname},
+			"displayName":   []string{*user.DisplayName},
+			"loginShell":    []string{*user.LoginShell},
+			"mail":          []string{*user.Mail},
+			"homeDirectory": []string{*user.HomeDir},
+			"userPassword":  []string{*user.UserPassword},
+		}),
+		ldap.NewEntry(dockerDN, map[string][]string{
+			"objectClass": objectClasses("posixGroup"),
+			"cn":          []string{"docker"},
+			"gidNumber":   []string{"2000"},
+			"memberUid":   []string{"johndoe"},
+		}),
+	}
+
+	if err = ldapReadPeople(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ldapReadGroups(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok = ldapPeople[dockerDN]; ok {
+		t.Errorf("unix group %s loaded as people group", dockerDN)
+	}
+
+	if _, ok = ldapUnixGroups[devopsDN]; ok {
+		t.Errorf("people group %s loaded as unix group", devopsDN)
+	}
+
+	if len(ldapOUs) != 1 {
+		t.Errorf("expected OU to be loaded once, got %d OUs", len(ldapOUs))
+	}
+
+	if err = comparePosixGroups(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = compareUnixGroups(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, task = range taskList {
+		t.Errorf("expected no tasks, got task type %d for %s", task.taskType, task.dn)
+	}
+}
+
+// TestLDAPReadLockedAccount verifies permanent locks are read from the lock attribute of the schema profile, as
+// pwdAccountLockedTime only exists with the ppolicy overlay of OpenLDAP
+func TestLDAPReadLockedAccount(t *testing.T) {
+	var (
+		dn    = "uid=johndoe,cn=devops,ou=people,dc=my-domain,dc=com"
+		tests = []struct {
+			profile   string
+			attribute string
+			value     string
+		}{
+			{"openldap-nis", "pwdAccountLockedTime", permanentLockTime},
+			{"389ds", "nsAccountLock", "true"},
+		}
+		i       int
+		account posixAccount
+		err     error
+	)
+
+	defer setupCompare(time.Now())()
+	defer func() {
+		activeSchema = nil
+		ldapPeople = nil
+		ldapOUs = nil
+	}()
+
+	for i = range tests {
+		activeSchema = schemaProfiles[tests[i].profile]
+		ldapPeople = map[string]posixGroup{}
+
+		if ldapAttribute("pwdAccountLockedTime") != tests[i].attribute {
+			t.Errorf("%s: expected accounts to be locked with %s, got %s", tests[i].profile, tests[i].attribute,
+				ldapAttribute("pwdAccountLockedTime"))
+		}
+
+		err = ldapReadPeople([]*ldap.Entry{
+			ldap.NewEntry(dn, map[string][]string{
+				"objectClass":      objectClasses("posixAccount"),
+				"cn":               []string{"johndoe"},
+				tests[i].attribute: []string{tests[i].value},
+			}),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		account = ldapPeople["cn=devops,ou=people,dc=my-domain,dc=com"].Objects[0]
+		if account.Locked == nil || !*account.Locked {
+			t.Errorf("%s: expected account with %s %s to be locked", tests[i].profile, tests[i].attribute,
+				tests[i].value)
+		}
+	}
+}
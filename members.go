@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// groupMemberFields contains all fields of member entries written as mapping
+var groupMemberFields = []string{"member", "from", "until", "reason", "ticket", "approved_by"}
+
+// memberMetadataFields contains the fields of member entries that only document a membership
+var memberMetadataFields = []string{"reason", "ticket", "approved_by"}
+
+// now is the point in time memberships are evaluated at, fixed for the whole run
+var now = time.Now()
+
+// groupMember is an entry of members in group files
+// it is either a plain username or group reference, or a mapping with the member and the time frame of the membership
+type groupMember struct {
+	// Member is a username or a group reference (see groupMemberPrefix)
+	Member string `yaml:"member"`
+	// From and Until limit the membership to a time frame; Until is the first moment the membership is expired
+	From  *time.Time `yaml:"from"`
+	Until *time.Time `yaml:"until"`
+	// Reason, Ticket and ApprovedBy document why a membership was granted; they are not written to LDAP
+	Reason     string `yaml:"reason"`
+	Ticket     string `yaml:"ticket"`
+	ApprovedBy string `yaml:"approved_by"`
+
+	node *yaml.Node `yaml:"-"` // position within file, used for error reporting
+}
+
+// memberPolicy contains rules for the member entries of a group file
+type memberPolicy struct {
+	// RequiredFields contains metadata fields every member entry must have (reason, ticket, approved_by)
+	RequiredFields []string `yaml:"required_fields"`
+}
+
+// plainGroupMember is used to decode mappings without calling UnmarshalYAML again
+type plainGroupMember groupMember
+
+// expiringMembership is a membership that expires within the expiry warning window
+type expiringMembership struct {
+	group  string
+	member string
+	until  time.Time
+}
+
+// UnmarshalYAML accepts a scalar or a mapping
+func (m *groupMember) UnmarshalYAML(node *yaml.Node) error {
+	var (
+		i      int
+		key    *yaml.Node
+		value  *yaml.Node
+		errors []string
+		err    error
+	)
+
+	m.node = node
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		m.Member = node.Value
+
+	case yaml.MappingNode:
+		for i = 0; i+1 < len(node.Content); i += 2 {
+			key = node.Content[i]
+			value = node.Content[i+1]
+
+			switch key.Value {
+			case "member":
+				m.Member = value.Value
+
+			case "from", "until":
+				if value.Kind != yaml.ScalarNode || value.ShortTag() != "!!timestamp" {
+					errors = append(errors, fmt.Sprintf("line %d: %s must be a date (2006-01-02) or a timestamp (2006-01-02T15:04:05Z)",
+						value.Line, key.Value))
+				}
+
+			case "reason", "ticket", "approved_by":
+				if value.Kind != yaml.ScalarNode {
+					errors = append(errors, fmt.Sprintf("line %d: %s must be a string", value.Line, key.Value))
+				}
+
+			default:
+				errors = append(errors, fmt.Sprintf("line %d: unknown field %q in member", key.Line, key.Value))
+			}
+		}
+
+		if m.Member == "" {
+			errors = append(errors, fmt.Sprintf("line %d: member is required", node.Line))
+		}
+
+		// the member name is kept to not report follow-up errors about it
+		if len(errors) > 0 {
+			return &yaml.TypeError{Errors: errors}
+		}
+
+		if err = node.Decode((*plainGroupMember)(m)); err != nil {
+			return err
+		}
+
+	default:
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: expected a username or a mapping", node.Line)}}
+	}
+
+	return nil
+}
+
+// active returns true if the membership is within its time frame at the given time
+func (m *groupMember) active(t time.Time) bool {
+	if m.From != nil && t.Before(*m.From) {
+		return false
+	}
+
+	if m.Until != nil && !t.Before(*m.Until) {
+		return false
+	}
+
+	return true
+}
+
+// inactiveReason describes why a membership isn't active at the given time
+func (m *groupMember) inactiveReason(t time.Time) string {
+	if m.From != nil && t.Before(*m.From) {
+		return fmt.Sprintf("membership starts on %s", formatTime(*m.From))
+	}
+
+	if m.Until != nil && !t.Before(*m.Until) {
+		return fmt.Sprintf("membership expired on %s", formatTime(*m.Until))
+	}
+
+	return ""
+}
+
+// memberRemovalNote explains why a member of a group in LDAP is to be removed
+func memberRemovalNote(group *groupOfNames, memberDN string) string {
+	var (
+		i      int
+		user   *posixAccount
+		reason string
+	)
+
+	for i = range group.Members {
+		if group.Members[i].active(now) {
+			continue
+		}
+
+		if user = findUser(group.Members[i].Member); user == nil || !strings.EqualFold(user.dn, memberDN) {
+			continue
+		}
+
+		reason = group.Members[i].inactiveReason(now)
+	}
+
+	if reason != "" {
+		return reason
+	}
+
+	return memberRuleRejection(group, memberDN)
+}
+
+// checkGroupMembers verifies the time frames of member entries and the fields required by the group's member policy
+func checkGroupMembers(file string, members []groupMember, policy *memberPolicy) {
+	var (
+		i     int
+		field string
+	)
+
+	for i = range members {
+		if members[i].From != nil && members[i].Until != nil && !members[i].From.Before(*members[i].Until) {
+			addConfigError(file, yamlPosition(members[i].node, "until"), "until must be after from")
+		}
+
+		if policy == nil || members[i].Member == "" {
+			continue
+		}
+
+		// unknown fields are reported by checkMemberPolicy
+		for _, field = range policy.RequiredFields {
+			if containsString(memberMetadataFields, field) && members[i].metadata(field) == "" {
+				addConfigError(file, members[i].node, "member %s requires %s", members[i].Member, field)
+			}
+		}
+	}
+}
+
+// checkMemberPolicy verifies the member policy of a group file
+// node is the mapping node of member_policy and only used for error positions
+func checkMemberPolicy(file string, node *yaml.Node, policy *memberPolicy) {
+	var i int
+
+	if policy == nil {
+		return
+	}
+
+	for i = range policy.RequiredFields {
+		if !containsString(memberMetadataFields, policy.RequiredFields[i]) {
+			addConfigError(file, yamlSequenceItem(yamlMappingValue(node, "required_fields"), i),
+				"unknown member field '%s', supported fields are %s", policy.RequiredFields[i],
+				strings.Join(memberMetadataFields, ", "))
+		}
+	}
+}
+
+// metadata returns the value of a metadata field of a member entry
+func (m *groupMember) metadata(field string) string {
+	switch field {
+	case "reason":
+		return m.Reason
+	case "ticket":
+		return m.Ticket
+	case "approved_by":
+		return m.ApprovedBy
+	}
+
+	return ""
+}
+
+// metadataNote returns all metadata of a member entry for audit output or an empty string if there is none
+func metadataNote(m *groupMember) string {
+	var (
+		parts []string
+		field string
+	)
+
+	if m == nil {
+		return ""
+	}
+
+	for _, field = range memberMetadataFields {
+		if m.metadata(field) != "" {
+			parts = append(parts, fmt.Sprintf("%s: %s", field, m.metadata(field)))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// activeMembers returns the member names of all entries that are active at the given time
+func activeMembers(members []groupMember, t time.Time) []string {
+	var (
+		names []string
+		i     int
+	)
+
+	for i = range members {
+		if members[i].active(t) {
+			names = append(names, members[i].Member)
+		}
+	}
+
+	return names
+}
+
+// findGroupMember returns the entry of a member name or nil
+func findGroupMember(members []groupMember, name string) *groupMember {
+	var i int
+
+	for i = range members {
+		if members[i].Member == name {
+			return &members[i]
+		}
+	}
+
+	return nil
+}
+
+// expiringMemberships returns all active memberships of group files that expire within the expiry warning window
+// sorted by their expiry
+func expiringMemberships() []expiringMembership {
+	var (
+		list   []expiringMembership
+		window time.Time
+		dn     string
+		groups map[string][]groupMember
+		i      int
+		m      *groupMember
+	)
+
+	window = now.AddDate(0, 0, *config.ExpiryWarningDays)
+
+	groups = make(map[string][]groupMember)
+	for dn = range localGroups {
+		groups[dn] = localGroups[dn].Members
+	}
+	for dn = range localUnixGroups {
+		groups[dn] = localUnixGroups[dn].members
+	}
+
+	for dn = range groups {
+		for i = range groups[dn] {
+			m = &groups[dn][i]
+
+			if m.Until != nil && m.active(now) && m.Until.Before(window) {
+				list = append(list, expiringMembership{group: dn, member: m.Member, until: *m.Until})
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].until.Equal(list[j].until) {
+			return list[i].group+list[i].member < list[j].group+list[j].member
+		}
+		return list[i].until.Before(list[j].until)
+	})
+
+	return list
+}
+
+// untilNote returns a note for audit output if a membership is limited in time
+func untilNote(m *groupMember) string {
+	if m == nil || m.Until == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (until %s)", formatTime(*m.Until))
+}
+
+// formatTime formats timestamps of memberships, dates without time are shown as date only
+func formatTime(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Location() == time.UTC {
+		return t.Format("2006-01-02")
+	}
+
+	return t.Format(time.RFC3339)
+}
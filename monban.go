@@ -49,6 +49,12 @@ var (
 	localGroups map[string]groupOfNames
 	// ldapGroups holds a map of all groups and their members existing in LDAP
 	ldapGroups map[string]groupOfNames
+	// localUnixGroups holds a map of all posixGroups defined in group_dir and their members
+	localUnixGroups map[string]posixGroup
+	// localRoles holds a map of all roles defined in roles_dir indexed by their name
+	localRoles map[string]role
+	// ldapUnixGroups holds a map of all posixGroups below groupDN and their members existing in LDAP
+	ldapUnixGroups map[string]posixGroup
 	// global LDAP connection struct
 	ldapCon *ldap.Conn
 	// global highest UIDNumber seen below peopleDN
@@ -59,6 +65,8 @@ var (
 	groupDN string
 	// taskList contains all tasks to be executed in order to sync LDAP with configured values
 	taskList []*actionTask
+	// configErrors contains all problems found while reading configuration files
+	configErrors []*configError
 
 	localOUs []*organizationalUnit
 	ldapOUs  []*organizationalUnit
@@ -184,17 +192,19 @@ func main() {
 					// sync order
 					// 1. create OUs
 					// 2. delete group memberships
-					// 3. delete posixAccounts
-					// 4. delete posixGroups
+					// 3. delete posixGroup memberships
+					// 4. delete posixAccounts
 					// 5. create posixGroups
-					// 6. update posixGroups
-					// 7. create posixAccounts
-					// 8. update posixAccounts
-					// 9. create groupOfNames
-					// 10. update groupOfNames
-					// 11. create group memberships
-					// 12. delete groupOfNames
-					// 13. delete OUs
+					// 6. delete posixGroups
+					// 7. update posixGroups
+					// 8. create posixAccounts
+					// 9. update posixAccounts
+					// 10. create posixGroup memberships
+					// 11. create groupOfNames
+					// 12. update groupOfNames
+					// 13. create group memberships
+					// 14. delete groupOfNames
+					// 15. delete OUs
 
 					var (
 						err    error
@@ -206,6 +216,11 @@ func main() {
 						return err
 					}
 
+					// sync never changes config files, generated usernames are written by validate --write-usernames
+					if err = checkGeneratedUsernames(); err != nil {
+						return err
+					}
+
 					if err = initLDAP(); err != nil {
 						return err
 					}
@@ -218,6 +233,10 @@ func main() {
 						return fmt.Errorf("failed to compare posixGroup objects: %s", err.Error())
 					}
 
+					if err = compareUnixGroups(); err != nil {
+						return fmt.Errorf("failed to compare unix posixGroup objects: %s", err.Error())
+					}
+
 					if err = compareGroupOfNames(); err != nil {
 						return fmt.Errorf("failed to compare groupOfNames objects: %s", err.Error())
 					}
@@ -252,7 +271,18 @@ func main() {
 						}
 					}
 
-					// 3. delete posixAccoounts
+					// 3. delete posixGroup memberships
+					glg.Infof("deleting obsolete posixGroup memberships")
+					for i = range taskList {
+						if taskList[i].objectType == objectTypePosixGroup &&
+							taskList[i].taskType == taskTypeDeleteMember {
+							if err = ldapDeletePosixGroupMember(taskList[i].dn, taskList[i].data.(string)); err != nil {
+								return err
+							}
+						}
+					}
+
+					// 4. delete posixAccounts
 					glg.Infof("deleting obsolete posixAccount objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypePosixAccount &&
@@ -263,7 +293,7 @@ func main() {
 						}
 					}
 
-					// 4. create posixGroups
+					// 5. create posixGroups
 					glg.Infof("creating new posixGroup objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypePosixGroup &&
@@ -274,7 +304,7 @@ func main() {
 						}
 					}
 
-					// 5. create posixGroups
+					// 6. delete posixGroups
 					glg.Infof("deleting posixGroup objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypePosixGroup &&
@@ -285,7 +315,7 @@ func main() {
 						}
 					}
 
-					// 6. update posixGroups
+					// 7. update posixGroups
 					glg.Infof("updating posixGroup objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypePosixGroup &&
@@ -296,7 +326,7 @@ func main() {
 						}
 					}
 
-					// 7. create posixAccounts
+					// 8. create posixAccounts
 					glg.Infof("creating new posixAccount objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypePosixAccount &&
@@ -307,7 +337,7 @@ func main() {
 						}
 					}
 
-					// 8. update posixAccounts
+					// 9. update posixAccounts
 					glg.Infof("updating posixAccount objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypePosixAccount &&
@@ -318,7 +348,18 @@ func main() {
 						}
 					}
 
-					// 9. create groupOfNames
+					// 10. create posixGroup memberships
+					glg.Infof("creating new posixGroup memberships")
+					for i = range taskList {
+						if taskList[i].objectType == objectTypePosixGroup &&
+							taskList[i].taskType == taskTypeAddMember {
+							if err = ldapAddPosixGroupMember(taskList[i].dn, taskList[i].data.(string)); err != nil {
+								return err
+							}
+						}
+					}
+
+					// 11. create groupOfNames
 					glg.Infof("creating new groupOfNames objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
@@ -329,7 +370,7 @@ func main() {
 						}
 					}
 
-					// 10. update groupOfNames
+					// 12. update groupOfNames
 					glg.Infof("updating groupOfNames objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
@@ -340,7 +381,7 @@ func main() {
 						}
 					}
 
-					// 11. create group memberships
+					// 13. create group memberships
 					glg.Infof("creating new groupOfNames memberships")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
@@ -351,7 +392,7 @@ func main() {
 						}
 					}
 
-					// 12. delete groupOfNames
+					// 14. delete groupOfNames
 					glg.Infof("deleteing groupOfNames objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
@@ -362,7 +403,7 @@ func main() {
 						}
 					}
 
-					// 13. delete OUs
+					// 15. delete OUs
 					glg.Infof("deleting intermediate organizationalUnit objects")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeOrganisationalUnit &&
@@ -395,8 +436,10 @@ func main() {
 				Usage:   "show diff between configured and existsing users/groups",
 				Action: func(c *cli.Context) error {
 					var (
-						i   int
-						err error
+						i    int
+						err  error
+						user *posixAccount
+						dn   string
 					)
 
 					if err = initConfig(c); err != nil {
@@ -415,6 +458,10 @@ func main() {
 						return fmt.Errorf("failed to compare posixGroup objects: %s", err.Error())
 					}
 
+					if err = compareUnixGroups(); err != nil {
+						return fmt.Errorf("failed to compare unix posixGroup objects: %s", err.Error())
+					}
+
 					if err = compareGroupOfNames(); err != nil {
 						return fmt.Errorf("failed to compare groupOfNames objects: %s", err.Error())
 					}
@@ -463,7 +510,7 @@ func main() {
 							taskList[i].taskType == taskTypeUpdate {
 
 							fmt.Printf("\n       -------\n       DN:           %s\n       NEW VALUES:\n",
-								taskList[i].data.(*posixGroup).CN)
+								taskList[i].dn)
 
 							if taskList[i].data.(*posixGroup).GIDNumber != nil {
 								fmt.Printf("         GID Number:     %d\n", *taskList[i].data.(*posixGroup).GIDNumber)
@@ -491,11 +538,27 @@ func main() {
 						if taskList[i].objectType == objectTypePosixAccount &&
 							taskList[i].taskType == taskTypeCreate {
 
-							fmt.Printf("\n       -------\n       Username:    %s\n       Given Name:  %s\n       Last Name:   %s\n       Group:       %s\n       -------\n",
+							fmt.Printf("\n       -------\n       Username:    %s\n       Given Name:  %s\n       Last Name:   %s\n       Group:       %s\n",
 								*taskList[i].data.(*posixAccount).UID,
 								*taskList[i].data.(*posixAccount).GivenName,
 								*taskList[i].data.(*posixAccount).Surname,
 								strings.Join(strings.Split(taskList[i].dn, ",")[1:], ","))
+
+							if taskList[i].data.(*posixAccount).Expires != nil {
+								fmt.Printf("       Expires:     %s\n", formatTime(*taskList[i].data.(*posixAccount).Expires))
+							}
+
+							if taskList[i].data.(*posixAccount).isDisabled(now) || taskList[i].data.(*posixAccount).isLocked() {
+								fmt.Printf("       State:       %s\n", accountState(taskList[i].data.(*posixAccount)))
+							}
+
+							if taskList[i].note != "" {
+								fmt.Printf("       Reason:      %s\n", taskList[i].note)
+							}
+
+							printAttributes("       ", taskList[i].data.(*posixAccount).Attributes)
+
+							fmt.Printf("       -------\n")
 						}
 					}
 
@@ -544,6 +607,28 @@ func main() {
 								fmt.Printf("         User Password:  ********\n")
 							}
 
+							if taskList[i].data.(*posixAccount).Expires != nil {
+								if taskList[i].data.(*posixAccount).Expires.IsZero() {
+									fmt.Printf("         Expires:        *to be deleted*\n")
+								} else {
+									fmt.Printf("         Expires:        %s\n", formatTime(*taskList[i].data.(*posixAccount).Expires))
+								}
+							}
+
+							if taskList[i].data.(*posixAccount).Disabled != nil {
+								fmt.Printf("         Disabled:       %t\n", *taskList[i].data.(*posixAccount).Disabled)
+							}
+
+							if taskList[i].data.(*posixAccount).Locked != nil {
+								fmt.Printf("         Locked:         %t\n", *taskList[i].data.(*posixAccount).Locked)
+							}
+
+							if taskList[i].note != "" {
+								fmt.Printf("         Reason:         %s\n", taskList[i].note)
+							}
+
+							printAttributes("         ", taskList[i].data.(*posixAccount).Attributes)
+
 							fmt.Printf("       -------\n")
 						}
 					}
@@ -553,23 +638,55 @@ func main() {
 						if taskList[i].objectType == objectTypePosixAccount &&
 							taskList[i].taskType == taskTypeDelete {
 
-							fmt.Printf("\n       -------\n       Username: %s\n       Given Name:  %s\n       Last Name:   %s\n       Group:       %s\n       -------\n",
+							fmt.Printf("\n       -------\n       Username: %s\n       Given Name:  %s\n       Last Name:   %s\n       Group:       %s\n",
 								*taskList[i].data.(*posixAccount).UID,
 								*taskList[i].data.(*posixAccount).GivenName,
 								*taskList[i].data.(*posixAccount).Surname,
 								strings.Join(strings.Split(taskList[i].dn, ",")[1:], ","))
+
+							if taskList[i].note != "" {
+								fmt.Printf("       Reason:      %s\n", taskList[i].note)
+							}
+
+							fmt.Printf("       -------\n")
 						}
 					}
 
+					fmt.Printf("\n     == Pending PosixAccount Objects ==\n")
+					for _, user = range pendingAccounts() {
+						fmt.Printf("\n       -------\n       Username:    %s\n       Start Date:  %s\n       Group:       %s\n",
+							*user.UID,
+							formatTime(*user.StartDate),
+							strings.Join(strings.Split(user.dn, ",")[1:], ","))
+
+						for _, dn = range pendingMemberships(user) {
+							fmt.Printf("       Member Of:   %s\n", dn)
+						}
+
+						fmt.Printf("       -------\n")
+					}
+
 					fmt.Printf("\n ==>> GroupOfNames Objects <<==\n")
 					fmt.Printf("\n     == New GroupOfNames Object ==\n")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
 							taskList[i].taskType == taskTypeCreate {
 
-							fmt.Printf("\n       -------\n       DN: %s\n       Description:  %s\n       -------\n",
+							fmt.Printf("\n       -------\n       DN: %s\n       Description:  %s\n",
 								taskList[i].data.(groupOfNames).dn,
 								taskList[i].data.(groupOfNames).Description)
+
+							if taskList[i].data.(groupOfNames).GIDNumber != nil {
+								fmt.Printf("       GID Number:   %d\n", *taskList[i].data.(groupOfNames).GIDNumber)
+							}
+
+							if len(taskList[i].data.(groupOfNames).ownerDNs) > 0 {
+								fmt.Printf("       Owners:       %s\n", strings.Join(taskList[i].data.(groupOfNames).ownerDNs, "; "))
+							}
+
+							printAttributes("       ", taskList[i].data.(groupOfNames).Attributes)
+
+							fmt.Printf("       -------\n")
 						}
 					}
 
@@ -584,6 +701,22 @@ func main() {
 								fmt.Printf("         Description:     %s\n", taskList[i].data.(*groupOfNames).Description)
 							}
 
+							if taskList[i].data.(*groupOfNames).deletePosixGroup {
+								fmt.Printf("         GID Number:      *to be deleted*\n")
+							} else if taskList[i].data.(*groupOfNames).GIDNumber != nil {
+								fmt.Printf("         GID Number:      %d\n", *taskList[i].data.(*groupOfNames).GIDNumber)
+							}
+
+							if taskList[i].data.(*groupOfNames).updateOwners {
+								if len(taskList[i].data.(*groupOfNames).ownerDNs) == 0 {
+									fmt.Printf("         Owners:          *to be deleted*\n")
+								} else {
+									fmt.Printf("         Owners:          %s\n", strings.Join(taskList[i].data.(*groupOfNames).ownerDNs, "; "))
+								}
+							}
+
+							printAttributes("         ", taskList[i].data.(*groupOfNames).Attributes)
+
 							fmt.Printf("       -------\n")
 						}
 					}
@@ -598,15 +731,56 @@ func main() {
 						}
 					}
 
+					fmt.Printf("\n ==>> PosixGroup Memberships <<==\n")
+					fmt.Printf("\n     == New PosixGroup Members ==\n")
+					for i = range taskList {
+						if taskList[i].objectType == objectTypePosixGroup &&
+							taskList[i].taskType == taskTypeAddMember {
+
+							fmt.Printf("\n       -------\n       Username:   %s\n       Group:      %s\n",
+								taskList[i].data.(string),
+								taskList[i].dn)
+
+							if taskList[i].note != "" {
+								fmt.Printf("       Reason:     %s\n", taskList[i].note)
+							}
+
+							fmt.Printf("       -------\n")
+						}
+					}
+
+					fmt.Printf("\n     == Deleted PosixGroup Members ==\n")
+					for i = range taskList {
+						if taskList[i].objectType == objectTypePosixGroup &&
+							taskList[i].taskType == taskTypeDeleteMember {
+
+							fmt.Printf("\n       -------\n       Username:   %s\n       Group:      %s\n",
+								taskList[i].data.(string),
+								taskList[i].dn)
+
+							if taskList[i].note != "" {
+								fmt.Printf("       Reason:     %s\n", taskList[i].note)
+							}
+
+							fmt.Printf("       -------\n")
+						}
+					}
+
 					fmt.Printf("\n ==>> GroupOfNames Memberships <<==\n")
 					fmt.Printf("\n     == New GroupOfNames Members ==\n")
 					for i = range taskList {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
 							taskList[i].taskType == taskTypeAddMember {
 
-							fmt.Printf("\n       -------\n       Username:   %s\n       Group:      %s\n       -------\n",
-								strings.Split(taskList[i].data.(string), ",")[0][4:],
+							fmt.Printf("\n       -------\n       Member:     %s\n       Group:      %s\n",
+								taskList[i].data.(string),
 								taskList[i].dn)
+
+							if taskList[i].note != "" {
+								fmt.Printf("       Reason:     %s\n", taskList[i].note)
+							}
+
+							fmt.Printf("       -------\n")
 						}
 					}
 
@@ -615,9 +789,15 @@ func main() {
 						if taskList[i].objectType == objectTypeGroupOfNames &&
 							taskList[i].taskType == taskTypeDeleteMember {
 
-							fmt.Printf("\n       -------\n       User Object: %s\n       Group:       %s\n       -------\n",
-								strings.Split(taskList[i].data.(string), ",")[0][4:],
+							fmt.Printf("\n       -------\n       Member:     %s\n       Group:      %s\n",
+								taskList[i].data.(string),
 								taskList[i].dn)
+
+							if taskList[i].note != "" {
+								fmt.Printf("       Reason:     %s\n", taskList[i].note)
+							}
+
+							fmt.Printf("       -------\n")
 						}
 					}
 
@@ -630,68 +810,203 @@ func main() {
 				Name:    "validate",
 				Aliases: []string{"v"},
 				Usage:   "validate config files and check for proper syntax",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "write-usernames",
+						Usage: "write generated usernames into people files",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					var err error
+					var (
+						err      error
+						expiring expiringMembership
+					)
 
 					if err = initConfig(c); err != nil {
 						return err
 					}
 
+					if c.Bool("write-usernames") {
+						if err = persistUsernames(); err != nil {
+							return err
+						}
+					}
+
+					for _, expiring = range expiringMemberships() {
+						glg.Warnf("membership of %s in %s expires on %s", expiring.member, expiring.group,
+							formatTime(expiring.until))
+					}
+
 					glg.Infof("validation complete - things seem okay *terms and conditions apply*")
 
 					return nil
 				},
 			},
 			&cli.Command{
-				Name:    "audit",
-				Aliases: []string{"a"},
-				Usage:   "displays all (in file) configured user objects and group membership for easy audit",
+				Name:  "review",
+				Usage: "exports access review sheets and applies the decisions made in them to group files",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:  "export",
+						Usage: "writes a review sheet (CSV) per group owner containing each membership",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "out",
+								Value: ".",
+								Usage: "write review sheets to `DIR`",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							var err error
+
+							if err = initConfig(c); err != nil {
+								return err
+							}
+
+							return exportReview(c.String("out"))
+						},
+					},
+					&cli.Command{
+						Name:      "apply",
+						Usage:     "removes all memberships marked with decision remove in the given review sheets from group files",
+						ArgsUsage: "SHEET [SHEET...]",
+						Action: func(c *cli.Context) error {
+							var err error
+
+							if c.NArg() == 0 {
+								return fmt.Errorf("no review sheet given")
+							}
+
+							if err = initConfig(c); err != nil {
+								return err
+							}
+
+							return applyReview(c.Args().Slice())
+						},
+					},
+				},
+			},
+			&cli.Command{
+				Name:  "passwd",
+				Usage: "hashes a password read from the terminal or stdin for use as user_password",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "scheme",
+						Value: "ssha512",
+						Usage: "hash scheme: " + strings.Join(passwordSchemes, ", "),
+					},
+					&cli.StringFlag{
+						Name:  "user",
+						Usage: "write the hash as user_password of `USERNAME` into its people file",
+					},
+				},
 				Action: func(c *cli.Context) error {
 					var (
-						err         error
-						dn          string
-						index       int
-						dnFragments []string
-						dn2         string
-						index2      int
+						err      error
+						password string
+						hash     string
+						user     *posixAccount
 					)
 
-					if err = initConfig(c); err != nil {
+					if !containsString(passwordSchemes, c.String("scheme")) {
+						return fmt.Errorf("unknown password scheme '%s', supported schemes are %s", c.String("scheme"),
+							strings.Join(passwordSchemes, ", "))
+					}
+
+					// read config first so a wrong username doesn't waste a typed password
+					if c.String("user") != "" {
+						if err = initConfig(c); err != nil {
+							return err
+						}
+
+						if user = findUser(c.String("user")); user == nil {
+							return fmt.Errorf("user %s doesn't exist", c.String("user"))
+						}
+
+						// scheme names of the policy are the upper case names of the passwd schemes
+						if len(config.PasswordPolicy.AllowedSchemes) > 0 &&
+							!containsString(config.PasswordPolicy.AllowedSchemes, strings.ToUpper(c.String("scheme"))) {
+							return fmt.Errorf("password scheme %s is not allowed by the password policy, allowed schemes are %s",
+								strings.ToUpper(c.String("scheme")), strings.Join(config.PasswordPolicy.AllowedSchemes, ", "))
+						}
+					}
+
+					if password, err = readPassword(); err != nil {
 						return err
 					}
 
-					glg.Infof("!! drifts between files and LDAP are not displayed")
+					if hash, err = hashPassword(password, c.String("scheme")); err != nil {
+						return err
+					}
 
-					fmt.Printf("\n\n====== START AUDIT ======")
+					if user == nil {
+						fmt.Println(hash)
+						return nil
+					}
 
-					for dn = range localPeople {
-						dnFragments = strings.Split(dn, ",")
+					if err = writeUserPassword(user, hash); err != nil {
+						return fmt.Errorf("failed to write user_password to %s: %s", user.file, err.Error())
+					}
 
-						fmt.Printf("\n  === %s ===\n\n", dnFragments[0][3:])
+					glg.Infof("wrote user_password of %s to %s", *user.UID, user.file)
 
-						for index = range localPeople[dn].Objects {
-							fmt.Printf("    -------\n")
+					return nil
+				},
+			},
+			&cli.Command{
+				Name:    "audit",
+				Aliases: []string{"a"},
+				Usage:   "displays all configured (or existing) user objects and group membership for easy audit",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "text",
+						Usage: "output format: " + strings.Join(auditFormats, ", "),
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "write the audit to `FILE` instead of stdout; a directory for format html",
+					},
+					&cli.StringFlag{
+						Name:  "view",
+						Value: "users",
+						Usage: "audit view: " + strings.Join(auditViews, ", ") + " (users with their groups or groups with their members)",
+					},
+					&cli.StringFlag{
+						Name:  "source",
+						Value: "file",
+						Usage: "build the audit from: " + strings.Join(auditSources, ", "),
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var err error
 
-							fmt.Printf("    Username: %s\n    Given Name: %s\n    Last Name: %s\n    Memberships:\n",
-								*localPeople[dn].Objects[index].UID,
-								*localPeople[dn].Objects[index].GivenName,
-								*localPeople[dn].Objects[index].Surname)
+					if !containsString(auditFormats, c.String("format")) {
+						return fmt.Errorf("unknown audit format '%s', supported formats are %s", c.String("format"),
+							strings.Join(auditFormats, ", "))
+					}
 
-							for dn2 = range localGroups {
-								for index2 = range localGroups[dn2].Members {
-									if *localPeople[dn].Objects[index].UID == localGroups[dn2].Members[index2] {
-										fmt.Printf("      %s\n", dn2)
-									}
-								}
-							}
+					if !containsString(auditViews, c.String("view")) {
+						return fmt.Errorf("unknown audit view '%s', supported views are %s", c.String("view"),
+							strings.Join(auditViews, ", "))
+					}
 
-							fmt.Printf("    -------\n")
-						}
+					if !containsString(auditSources, c.String("source")) {
+						return fmt.Errorf("unknown audit source '%s', supported sources are %s", c.String("source"),
+							strings.Join(auditSources, ", "))
+					}
+
+					if err = initConfig(c); err != nil {
+						return err
 					}
 
-					fmt.Printf("====== END AUDIT ======\n")
+					if c.String("source") == "file" {
+						glg.Infof("!! drifts between files and LDAP are not displayed, use --source both to include them")
+					} else if err = initLDAP(); err != nil {
+						return err
+					}
 
-					return nil
+					return writeAudit(buildAudit(c.String("source")), c.String("format"), c.String("view"), c.String("out"))
 				},
 			},
 		},
@@ -703,12 +1018,13 @@ func main() {
 	}
 }
 
-// initConfig reads config files
+// initConfig reads config files and reports all errors found within them
 func initConfig(c *cli.Context) error {
 	var err error
 
 	err = readConfiguration(c)
 	if err != nil {
+		printConfigErrors()
 		return fmt.Errorf("failed to read main config file: %s", err.Error())
 	}
 
@@ -717,12 +1033,18 @@ func initConfig(c *cli.Context) error {
 		return fmt.Errorf("failed to read people configuration file: %s", err.Error())
 	}
 
+	err = readRoleConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to read role configuration file: %s", err.Error())
+	}
+
+	// groups are read even when people files contain errors to report all problems at once
 	err = readGroupConfiguration()
 	if err != nil {
 		return fmt.Errorf("failed to read groups configuration file: %s", err.Error())
 	}
 
-	return nil
+	return printConfigErrors()
 }
 
 // initLDAP connects to LDAP, authenticates and reads object details
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v3"
+)
+
+// passwordSchemes contains all hash schemes supported by the passwd command
+var passwordSchemes = []string{"ssha", "ssha512", "crypt-sha512", "bcrypt", "argon2"}
+
+// passwordSchemeRegex matches the scheme prefix of userPassword values, e.g. {SSHA512}
+var passwordSchemeRegex = regexp.MustCompile(`^{([A-Za-z0-9.-]+)}`)
+
+// passthroughSchemes contains schemes that delegate authentication instead of storing a hash; they are the only ones
+// that make sense within templated defaults (e.g. {SASL}%u)
+var passthroughSchemes = []string{"SASL", "KERBEROS"}
+
+// cryptSchemes names the variants of {CRYPT} hashes by the prefix of the hash, other {CRYPT} hashes (e.g. DES) are
+// named CRYPT
+var cryptSchemes = []struct {
+	prefix string
+	name   string
+}{
+	{"$6$", "CRYPT-SHA512"},
+	{"$5$", "CRYPT-SHA256"},
+	{"$1$", "CRYPT-MD5"},
+	{"$2a$", "BCRYPT"},
+	{"$2b$", "BCRYPT"},
+	{"$2y$", "BCRYPT"},
+}
+
+// cryptAlphabet is the base64 alphabet of crypt(3) hashes and salts
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// limits of SHA-512 crypt; rounds out of range are clamped and salts truncated as defined by the specification
+const (
+	sha512CryptMinRounds = 1000
+	sha512CryptMaxRounds = 999999999
+	sha512CryptSaltLen   = 16
+)
+
+// argon2 parameters as recommended by RFC 9106 for memory constrained environments (argon2id, 64 MiB)
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+)
+
+// hashPassword returns the LDAP userPassword value of a password using the given scheme
+func hashPassword(password string, scheme string) (string, error) {
+	var (
+		salt   []byte
+		digest []byte
+		i      int
+		err    error
+	)
+
+	switch scheme {
+	case "ssha":
+		if salt, err = randomBytes(8); err != nil {
+			return "", err
+		}
+
+		return "{SSHA}" + saltedDigest(sha1.New(), password, salt), nil
+
+	case "ssha512":
+		if salt, err = randomBytes(16); err != nil {
+			return "", err
+		}
+
+		return "{SSHA512}" + saltedDigest(sha512.New(), password, salt), nil
+
+	case "crypt-sha512":
+		if salt, err = randomBytes(16); err != nil {
+			return "", err
+		}
+
+		// the salt consists of characters of the crypt alphabet only
+		for i = range salt {
+			salt[i] = cryptAlphabet[int(salt[i])%len(cryptAlphabet)]
+		}
+
+		return "{CRYPT}" + sha512Crypt([]byte(password), salt, 0), nil
+
+	case "bcrypt":
+		if len(password) > 72 {
+			return "", fmt.Errorf("bcrypt only supports passwords up to 72 bytes")
+		}
+
+		if digest, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			return "", err
+		}
+
+		return "{CRYPT}" + string(digest), nil
+
+	case "argon2":
+		if salt, err = randomBytes(16); err != nil {
+			return "", err
+		}
+
+		digest = argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+		// format of the OpenLDAP argon2 module (libargon2 encoding)
+		return fmt.Sprintf("{ARGON2}$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time,
+			argon2Threads, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(digest)), nil
+	}
+
+	return "", fmt.Errorf("unknown password scheme '%s', supported schemes are %s", scheme,
+		strings.Join(passwordSchemes, ", "))
+}
+
+// randomBytes returns n bytes read from the system's secure random source
+func randomBytes(n int) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	data = make([]byte, n)
+
+	if _, err = rand.Read(data); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %s", err.Error())
+	}
+
+	return data, nil
+}
+
+// saltedDigest returns base64(hash(password + salt) + salt) as used by the salted SHA schemes of OpenLDAP
+func saltedDigest(h hash.Hash, password string, salt []byte) string {
+	h.Write([]byte(password))
+	h.Write(salt)
+
+	return base64.StdEncoding.EncodeToString(append(h.Sum(nil), salt...))
+}
+
+// sha512Crypt returns the SHA-512 based crypt(3) hash ($6$) of a password
+// rounds of 0 uses the default of 5000 rounds, which isn't written to the hash; other values are written as rounds=N
+// see https://www.akkadia.org/drepper/SHA-crypt.txt
+func sha512Crypt(password []byte, salt []byte, rounds int) string {
+	var (
+		a      hash.Hash
+		b      hash.Hash
+		c      []byte
+		digest []byte
+		p      []byte
+		s      []byte
+		i      int
+		n      int
+		result strings.Builder
+	)
+
+	if len(salt) > sha512CryptSaltLen {
+		salt = salt[:sha512CryptSaltLen]
+	}
+
+	n = rounds
+	switch {
+	case rounds == 0:
+		n = 5000
+	case rounds < sha512CryptMinRounds:
+		rounds = sha512CryptMinRounds
+		n = rounds
+	case rounds > sha512CryptMaxRounds:
+		rounds = sha512CryptMaxRounds
+		n = rounds
+	}
+
+	b = sha512.New()
+	b.Write(password)
+	b.Write(salt)
+	b.Write(password)
+	digest = b.Sum(nil)
+
+	a = sha512.New()
+	a.Write(password)
+	a.Write(salt)
+
+	for i = len(password); i > 64; i -= 64 {
+		a.Write(digest)
+	}
+	a.Write(digest[:i])
+
+	for i = len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			a.Write(digest)
+		} else {
+			a.Write(password)
+		}
+	}
+	c = a.Sum(nil)
+
+	// sequence P is based on the password, sequence S on the salt
+	b = sha512.New()
+	for i = 0; i < len(password); i++ {
+		b.Write(password)
+	}
+	p = repeatDigest(b.Sum(nil), len(password))
+
+	b = sha512.New()
+	for i = 0; i < 16+int(c[0]); i++ {
+		b.Write(salt)
+	}
+	s = repeatDigest(b.Sum(nil), len(salt))
+
+	for i = 0; i < n; i++ {
+		a = sha512.New()
+
+		if i&1 != 0 {
+			a.Write(p)
+		} else {
+			a.Write(c)
+		}
+
+		if i%3 != 0 {
+			a.Write(s)
+		}
+
+		if i%7 != 0 {
+			a.Write(p)
+		}
+
+		if i&1 != 0 {
+			a.Write(c)
+		} else {
+			a.Write(p)
+		}
+
+		c = a.Sum(nil)
+	}
+
+	result.WriteString("$6$")
+	if rounds != 0 {
+		fmt.Fprintf(&result, "rounds=%d$", rounds)
+	}
+	result.Write(salt)
+	result.WriteString("$")
+
+	// bytes are encoded in groups of three in the order defined by the specification
+	for i = 0; i < 21; i++ {
+		cryptEncode(&result, c[sha512CryptOrder[i][0]], c[sha512CryptOrder[i][1]], c[sha512CryptOrder[i][2]], 4)
+	}
+	cryptEncode(&result, 0, 0, c[63], 2)
+
+	return result.String()
+}
+
+// sha512CryptOrder contains the byte order of the encoded SHA-512 crypt digest
+var sha512CryptOrder = [21][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48}, {28, 49, 7},
+	{50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35}, {15, 36, 57},
+	{37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+}
+
+// repeatDigest repeats a digest until it is n bytes long
+func repeatDigest(digest []byte, n int) []byte {
+	var result []byte
+
+	for len(result) < n {
+		result = append(result, digest...)
+	}
+
+	return result[:n]
+}
+
+// cryptEncode writes n characters of the crypt alphabet encoding three bytes
+func cryptEncode(out *strings.Builder, b2 byte, b1 byte, b0 byte, n int) {
+	var w uint
+
+	w = uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+
+	for ; n > 0; n-- {
+		out.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// readPassword reads a password from the terminal, asking for it twice, or the first line of stdin
+func readPassword() (string, error) {
+	var (
+		password []byte
+		confirm  []byte
+		line     string
+		err      error
+	)
+
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err = terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %s", err.Error())
+		}
+
+		fmt.Fprint(os.Stderr, "Retype password: ")
+		confirm, err = terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %s", err.Error())
+		}
+
+		if string(password) != string(confirm) {
+			return "", fmt.Errorf("passwords don't match")
+		}
+
+		line = string(password)
+	} else {
+		line, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read password: %s", err.Error())
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+	}
+
+	if line == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+
+	return line, nil
+}
+
+// writeUserPassword sets user_password of a user within its people file
+// the line of an existing user_password is replaced, otherwise it is added as first attribute of the object; all other
+// lines of the file stay untouched
+func writeUserPassword(user *posixAccount, password string) error {
+	var (
+		info    os.FileInfo
+		content []byte
+		doc     yaml.Node
+		objects *yaml.Node
+		item    *yaml.Node
+		key     *yaml.Node
+		current *yaml.Node
+		lines   []string
+		line    string
+		value   []byte
+		found   bool
+		i       int
+		err     error
+	)
+
+	if info, err = os.Stat(user.file); err != nil {
+		return err
+	}
+
+	if content, err = ioutil.ReadFile(user.file); err != nil {
+		return err
+	}
+
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Content) == 0 {
+		return fmt.Errorf("file is empty")
+	}
+
+	if objects = yamlMappingValue(doc.Content[0], "objects"); objects == nil {
+		return fmt.Errorf("no objects found")
+	}
+
+	for _, item = range objects.Content {
+		if item.Line == user.node.Line && item.Column == user.node.Column {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("object of username %s not found, was the file changed?", *user.UID)
+	}
+
+	if item.Kind != yaml.MappingNode || item.Style&yaml.FlowStyle != 0 {
+		return fmt.Errorf("object of username %s must be written as block mapping", *user.UID)
+	}
+
+	// let yaml quote the value, hashes start with {
+	if value, err = yaml.Marshal(password); err != nil {
+		return err
+	}
+
+	lines = strings.Split(string(content), "\n")
+
+	for i = 0; i+1 < len(item.Content); i += 2 {
+		if item.Content[i].Value == "user_password" {
+			key = item.Content[i]
+			current = item.Content[i+1]
+		}
+	}
+
+	if key == nil {
+		line = lines[item.Line-1]
+		lines[item.Line-1] = fmt.Sprintf("%suser_password: %s\n%s%s",
+			line[:item.Column-1],
+			strings.TrimSpace(string(value)),
+			strings.Repeat(" ", item.Column-1),
+			line[item.Column-1:])
+	} else {
+		if current.Kind != yaml.ScalarNode || current.Line != key.Line ||
+			current.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return fmt.Errorf("user_password of username %s must be written on a single line", *user.UID)
+		}
+
+		line = lines[key.Line-1]
+		lines[key.Line-1] = fmt.Sprintf("%suser_password: %s", line[:key.Column-1], strings.TrimSpace(string(value)))
+	}
+
+	return ioutil.WriteFile(user.file, []byte(strings.Join(lines, "\n")), info.Mode())
+}
+
+// passwordScheme returns the scheme of a userPassword value in upper case or an empty string for cleartext
+// {CRYPT} hashes are named by their algorithm (see cryptSchemes) so policies can tell them apart
+func passwordScheme(password string) string {
+	var (
+		match  []string
+		scheme string
+		i      int
+	)
+
+	if match = passwordSchemeRegex.FindStringSubmatch(password); match == nil {
+		return ""
+	}
+
+	scheme = strings.ToUpper(match[1])
+
+	if scheme == "CRYPT" {
+		for i = range cryptSchemes {
+			if strings.HasPrefix(password[len(match[0]):], cryptSchemes[i].prefix) {
+				return cryptSchemes[i].name
+			}
+		}
+	}
+
+	return scheme
+}
+
+// checkPasswordPolicyConfig verifies the password policy of the main config
+// node is the mapping node of password_policy, nil if it isn't set which disables all password checks
+func checkPasswordPolicyConfig(node *yaml.Node) {
+	var i int
+
+	config.PasswordPolicy.enabled = node != nil
+
+	for i = range config.PasswordPolicy.AllowedSchemes {
+		if !passwordSchemeRegex.MatchString("{" + config.PasswordPolicy.AllowedSchemes[i] + "}") {
+			addConfigError(configFile, yamlSequenceItem(yamlMappingValue(node, "allowed_schemes"), i),
+				"invalid password scheme '%s', give schemes without braces, e.g. SSHA512",
+				config.PasswordPolicy.AllowedSchemes[i])
+		}
+
+		config.PasswordPolicy.AllowedSchemes[i] = strings.ToUpper(config.PasswordPolicy.AllowedSchemes[i])
+	}
+}
+
+// passwordPolicyViolation returns why a userPassword value violates the password policy or an empty string
+func passwordPolicyViolation(password string) string {
+	var scheme string
+
+	if !config.PasswordPolicy.enabled {
+		return ""
+	}
+
+	scheme = passwordScheme(password)
+
+	if scheme == "" {
+		if config.PasswordPolicy.AllowCleartext {
+			return ""
+		}
+
+		return "cleartext passwords are not allowed, use monban passwd to create a hash"
+	}
+
+	if len(config.PasswordPolicy.AllowedSchemes) > 0 && !containsString(config.PasswordPolicy.AllowedSchemes, scheme) {
+		return fmt.Sprintf("password scheme %s is not allowed, allowed schemes are %s", scheme,
+			strings.Join(config.PasswordPolicy.AllowedSchemes, ", "))
+	}
+
+	return ""
+}
+
+// checkDefaultPassword verifies a user_password default against the password policy
+// defaults apply to many users, so they must delegate authentication (e.g. {SASL}%u) and depend on the user as
+// otherwise every user shares the same password
+func checkDefaultPassword(file string, node *yaml.Node, tmpl string) {
+	var (
+		scheme    string
+		violation string
+	)
+
+	if !config.PasswordPolicy.enabled {
+		return
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(templatePlaceholders.Replace(tmpl)), "{{") {
+		addConfigError(file, node, "the password scheme of default user_password must not be templated")
+		return
+	}
+
+	if violation = passwordPolicyViolation(tmpl); violation != "" {
+		addConfigError(file, node, "invalid default user_password: %s", violation)
+		return
+	}
+
+	scheme = passwordScheme(tmpl)
+
+	if scheme != "" && !containsString(passthroughSchemes, scheme) {
+		addConfigError(file, node, "default user_password contains a {%s} hash which every user would share, "+
+			"set user_password per object or use a scheme like {SASL}%%u", scheme)
+		return
+	}
+
+	if !strings.Contains(templatePlaceholders.Replace(tmpl), "{{") {
+		addConfigError(file, node, "default user_password is the same for every user, use a template like {SASL}%%u")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestSHA512Crypt verifies sha512Crypt against the test vectors of the specification
+// see https://www.akkadia.org/drepper/SHA-crypt.txt
+func TestSHA512Crypt(t *testing.T) {
+	var (
+		vectors = []struct {
+			password string
+			salt     string
+			rounds   int
+			expected string
+		}{
+			{"Hello world!", "saltstring", 0,
+				"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+			{"Hello world!", "saltstringsaltstring", 10000,
+				"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+			{"This is just a test", "toolongsaltstring", 5000,
+				"$6$rounds=5000$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0"},
+			{"a very much longer text to encrypt.  This one even stretches over morethan one line.",
+				"anotherlongsaltstring", 1400,
+				"$6$rounds=1400$anotherlongsalts$POfYwTEok97VWcjxIiSOjiykti.o/pQs.wPvMxQ6Fm7I6IoYN3CmLs66x9t0oSwbtEW7o7UmJEiDwGqd8p4ur1"},
+			{"we have a short salt string but not a short password", "short", 77777,
+				"$6$rounds=77777$short$WuQyW2YR.hBNpjjRhpYD/ifIw05xdfeEyQoMxIXbkvr0gge1a1x3yRULJ5CCaUeOxFmtlcGZelFl5CxtgfiAc0"},
+			{"a short string", "asaltof16chars..", 123456,
+				"$6$rounds=123456$asaltof16chars..$BtCwjqMJGx5hrJhZywWvt0RLE8uZ4oPwcelCjmw2kSYu.Ec6ycULevoBK25fs2xXgMNrCzIMVcgEJAstJeonj1"},
+			{"the minimum number is still observed", "roundstoolow", 10,
+				"$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX."},
+		}
+		i      int
+		result string
+	)
+
+	for i = range vectors {
+		result = sha512Crypt([]byte(vectors[i].password), []byte(vectors[i].salt), vectors[i].rounds)
+		if result != vectors[i].expected {
+			t.Errorf("vector %d: expected %s, got %s", i+1, vectors[i].expected, result)
+		}
+	}
+}
+
+// TestHashPassword verifies the hashes of all schemes of the passwd command can be verified with the password
+func TestHashPassword(t *testing.T) {
+	var (
+		password = "correct horse battery staple"
+		scheme   string
+		hashed   string
+		other    string
+		err      error
+	)
+
+	for _, scheme = range passwordSchemes {
+		if hashed, err = hashPassword(password, scheme); err != nil {
+			t.Errorf("%s: %s", scheme, err.Error())
+			continue
+		}
+
+		if err = verifyPassword(password, hashed); err != nil {
+			t.Errorf("%s: %s", scheme, err.Error())
+		}
+
+		// every hash uses a new salt
+		if other, _ = hashPassword(password, scheme); other == hashed {
+			t.Errorf("%s: same hash for two calls, salt isn't random", scheme)
+		}
+	}
+
+	if _, err = hashPassword(password, "md5"); err == nil {
+		t.Errorf("expected error for unknown scheme")
+	}
+}
+
+// verifyPassword checks a userPassword value created by hashPassword against the password
+func verifyPassword(password string, hashed string) error {
+	var (
+		data   []byte
+		parts  []string
+		salt   []byte
+		digest []byte
+		memory uint32
+		time   uint32
+		thread uint8
+		err    error
+	)
+
+	switch {
+	case strings.HasPrefix(hashed, "{SSHA}"):
+		return verifySaltedDigest(sha1.New(), password, strings.TrimPrefix(hashed, "{SSHA}"))
+
+	case strings.HasPrefix(hashed, "{SSHA512}"):
+		return verifySaltedDigest(sha512.New(), password, strings.TrimPrefix(hashed, "{SSHA512}"))
+
+	case strings.HasPrefix(hashed, "{CRYPT}$6$"):
+		parts = strings.Split(hashed, "$")
+		if sha512Crypt([]byte(password), []byte(parts[2]), 0) != strings.TrimPrefix(hashed, "{CRYPT}") {
+			return fmt.Errorf("SHA-512 crypt hash doesn't match")
+		}
+		return nil
+
+	case strings.HasPrefix(hashed, "{CRYPT}$2"):
+		return bcrypt.CompareHashAndPassword([]byte(strings.TrimPrefix(hashed, "{CRYPT}")), []byte(password))
+
+	case strings.HasPrefix(hashed, "{ARGON2}$argon2id$"):
+		// $argon2id$v=19$m=65536,t=3,p=4$salt$digest
+		parts = strings.Split(strings.TrimPrefix(hashed, "{ARGON2}"), "$")
+		if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &thread); err != nil {
+			return err
+		}
+
+		if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+			return err
+		}
+
+		if digest, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+			return err
+		}
+
+		data = argon2.IDKey([]byte(password), salt, time, memory, thread, uint32(len(digest)))
+		if !bytes.Equal(data, digest) {
+			return fmt.Errorf("argon2 hash doesn't match")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown hash %s", hashed)
+}
+
+// verifySaltedDigest checks a base64(hash(password + salt) + salt) value against the password
+func verifySaltedDigest(h hash.Hash, password string, encoded string) error {
+	var (
+		data []byte
+		size int
+		err  error
+	)
+
+	if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+		return err
+	}
+
+	size = h.Size()
+	if len(data) <= size {
+		return fmt.Errorf("salted digest without salt")
+	}
+
+	h.Write([]byte(password))
+	h.Write(data[size:])
+
+	if !bytes.Equal(h.Sum(nil), data[:size]) {
+		return fmt.Errorf("salted digest doesn't match")
+	}
+
+	return nil
+}
+
+// TestPasswordPolicyViolation verifies user_password values are only checked if password_policy is set
+func TestPasswordPolicyViolation(t *testing.T) {
+	var violation string
+
+	config = &configuration{}
+	defer func() {
+		config = nil
+	}()
+
+	if violation = passwordPolicyViolation("secret"); violation != "" {
+		t.Errorf("expected no violation without password_policy, got %s", violation)
+	}
+
+	config.PasswordPolicy.enabled = true
+	if passwordPolicyViolation("secret") == "" {
+		t.Errorf("expected cleartext password to violate the password policy")
+	}
+
+	config.PasswordPolicy.AllowCleartext = true
+	if violation = passwordPolicyViolation("secret"); violation != "" {
+		t.Errorf("expected cleartext password to be allowed, got %s", violation)
+	}
+
+	config.PasswordPolicy.AllowedSchemes = []string{"SSHA512"}
+	if passwordPolicyViolation("{CRYPT}$6$salt$hash") == "" {
+		t.Errorf("expected CRYPT-SHA512 to violate the allowed schemes")
+	}
+
+	if violation = passwordPolicyViolation("{ssha512}hash"); violation != "" {
+		t.Errorf("expected SSHA512 to be allowed, got %s", violation)
+	}
+}
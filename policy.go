@@ -0,0 +1,169 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// attribute policies decide whether Monban keeps an attribute of people objects in sync
+const (
+	// attributeManaged attributes are set on creation and updated whenever they differ (default)
+	attributeManaged = "managed"
+	// attributeSetOnCreate attributes are only set on creation, e.g. passwords users change via self-service
+	attributeSetOnCreate = "set_on_create"
+	// attributeIgnore attributes are neither set nor compared
+	attributeIgnore = "ignore"
+)
+
+// attributePolicies contains all supported attribute policies
+var attributePolicies = []string{attributeManaged, attributeSetOnCreate, attributeIgnore}
+
+// policyAttributes contains the attributes of people objects an attribute policy can be set for in addition to the
+// additional attributes declared in schema
+var policyAttributes = []string{
+	"given_name", "surname", "display_name", "login_shell", "mail", "ssh_public_key", "home_dir", "user_password",
+}
+
+// requiredAttributes contains the attributes that must be set on creation of people objects and thus can't be ignored
+var requiredAttributes = []string{"surname", "home_dir"}
+
+// checkAttributePolicy verifies an attribute policy of the main config or a people object
+// node is the mapping node of attribute_policy and only used for error positions; names of additional attributes are
+// rewritten to the spelling used in the schema config
+func checkAttributePolicy(file string, node *yaml.Node, policy map[string]string) {
+	var (
+		names    []string
+		name     string
+		declared string
+		value    string
+	)
+
+	for name = range policy {
+		names = append(names, name)
+	}
+
+	// report errors in a stable order
+	sort.Strings(names)
+
+	for _, name = range names {
+		value = policy[name]
+		declared = name
+
+		if !containsString(policyAttributes, name) {
+			if declared = schemaAttributeName(objectTypePosixAccount, name); declared == "" {
+				addConfigError(file, yamlKeyPosition(node, name),
+					"attribute policy can't be set for '%s', supported attributes are %s and additional attributes of posixAccount",
+					name, strings.Join(policyAttributes, ", "))
+				continue
+			}
+		}
+
+		if !containsString(attributePolicies, value) {
+			addConfigError(file, yamlPosition(node, name), "unknown attribute policy '%s', supported policies are %s",
+				value, strings.Join(attributePolicies, ", "))
+			continue
+		}
+
+		if value == attributeIgnore && containsString(requiredAttributes, name) {
+			addConfigError(file, yamlPosition(node, name),
+				"%s is required to create objects and can't be ignored, use %s instead", name, attributeSetOnCreate)
+		}
+
+		if declared != name {
+			delete(policy, name)
+			policy[declared] = value
+		}
+	}
+}
+
+// attributePolicy returns the policy of an attribute of a people object
+// the policy of the object takes precedence over the one of the main config
+func (u *posixAccount) attributePolicy(name string) string {
+	var (
+		policy string
+		ok     bool
+	)
+
+	if policy, ok = u.AttributePolicy[name]; ok {
+		return policy
+	}
+
+	if policy, ok = config.AttributePolicy[name]; ok {
+		return policy
+	}
+
+	return attributeManaged
+}
+
+// managed returns true if an attribute of an existing object is kept in sync with the config
+func (u *posixAccount) managed(name string) bool {
+	return u.attributePolicy(name) == attributeManaged
+}
+
+// created returns true if an attribute is set when the object is created
+func (u *posixAccount) created(name string) bool {
+	return u.attributePolicy(name) != attributeIgnore
+}
+
+// missingAttributes returns the attributes with a default that are set on creation but have no value, which only
+// happens for attributes with policy set_on_create
+func (u *posixAccount) missingAttributes() []string {
+	var (
+		name    string
+		missing []string
+	)
+
+	for _, name = range defaultAttributes {
+		if u.defaultValue(name) == nil && u.created(name) {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// managedAttributes returns the additional attributes whose policy is managed
+func (u *posixAccount) managedAttributes(attributes map[string]attributeValues) map[string]attributeValues {
+	var (
+		result map[string]attributeValues
+		name   string
+	)
+
+	for name = range attributes {
+		if !u.managed(name) {
+			continue
+		}
+
+		if result == nil {
+			result = make(map[string]attributeValues)
+		}
+
+		result[name] = attributes[name]
+	}
+
+	return result
+}
+
+// createdAttributes returns the additional attributes set when the object is created
+func (u *posixAccount) createdAttributes(attributes map[string]attributeValues) map[string]attributeValues {
+	var (
+		result map[string]attributeValues
+		name   string
+	)
+
+	for name = range attributes {
+		if !u.created(name) {
+			continue
+		}
+
+		if result == nil {
+			result = make(map[string]attributeValues)
+		}
+
+		result[name] = attributes[name]
+	}
+
+	return result
+}
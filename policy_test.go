@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestApplyDefaultPolicy verifies attributes that aren't managed don't need a value or default
+func TestApplyDefaultPolicy(t *testing.T) {
+	var (
+		dn     = "cn=devops,ou=people,dc=my-domain,dc=com"
+		gid    = 1001
+		layers = []*defaultsLayer{&defaultsLayer{name: "main config", values: &defaults{}}}
+		user   posixAccount
+		err    error
+	)
+
+	config = &configuration{AttributePolicy: map[string]string{
+		"mail":          attributeIgnore,
+		"user_password": attributeSetOnCreate,
+	}}
+	configErrors = nil
+	defer func() {
+		config = nil
+		configErrors = nil
+	}()
+
+	user = testAccount("johndoe")
+	user.file = "people/devops"
+	user.node = &yaml.Node{Line: 3, Column: 5}
+	user.sources = make(map[string]string)
+	user.Mail = nil
+	user.UserPassword = nil
+	user.LoginShell = nil
+
+	applyDefault(&user, "mail", &user.Mail, layers, nil)
+	applyDefault(&user, "user_password", &user.UserPassword, layers, nil)
+	if len(configErrors) != 0 {
+		t.Errorf("expected no error for attributes that aren't managed, got %s", configErrors[0].Error())
+	}
+
+	applyDefault(&user, "login_shell", &user.LoginShell, layers, nil)
+	if len(configErrors) != 1 {
+		t.Errorf("expected error for managed attribute without value and default, got %d errors", len(configErrors))
+	}
+
+	// set_on_create attributes without value only fail if the object is created
+	defer setupCompare(time.Now())()
+	config.AttributePolicy = map[string]string{"mail": attributeIgnore, "user_password": attributeSetOnCreate}
+	user.LoginShell = new(string)
+
+	localPeople = map[string]posixGroup{dn: posixGroup{dn: dn, GIDNumber: &gid, Objects: []posixAccount{user}}}
+	ldapPeople = map[string]posixGroup{
+		dn: posixGroup{dn: dn, GIDNumber: &gid, Objects: []posixAccount{testAccount("johndoe")}},
+	}
+	defer func() {
+		localPeople = nil
+		ldapPeople = nil
+	}()
+
+	if err = comparePosixGroups(); err != nil {
+		t.Errorf("expected existing account to be compared, got %s", err.Error())
+	}
+
+	ldapPeople[dn] = posixGroup{dn: dn, GIDNumber: &gid}
+	if err = comparePosixGroups(); err == nil {
+		t.Errorf("expected error for account created without user_password")
+	}
+}
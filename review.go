@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kpango/glg"
+	"gopkg.in/yaml.v3"
+)
+
+// reviewCSVHeader contains the columns of review sheets
+// reviewers fill in decision (keep or remove) and optionally comment, all other columns must not be changed
+var reviewCSVHeader = []string{
+	"group_file", "group", "group_type", "member", "username", "via", "until", "reason", "ticket", "approved_by",
+	"decision", "comment",
+}
+
+// reviewDecisions contains all decisions a reviewer can make, an empty decision keeps the membership
+var reviewDecisions = []string{"keep", "remove"}
+
+// reviewUnassigned is the name of the review sheet containing groups without owner
+const reviewUnassigned = "unassigned"
+
+// reviewRemoval is a member entry marked for removal within a review sheet
+type reviewRemoval struct {
+	file   string // group file relative to group_dir
+	member string // member entry as written in the group file
+	sheet  string // review sheet and line the removal came from, used for messages
+}
+
+// exportReview writes one review sheet per group owner into dir
+// every sheet contains all member entries of the groups owned, memberships granted by nested groups, roles or member
+// rules are listed for information with the origin in via as they can't be removed within the group file
+func exportReview(dir string) error {
+	var (
+		report  *auditReport
+		sheets  map[string][][]string
+		group   *auditGroup
+		rows    [][]string
+		owner   string
+		owners  []string
+		err     error
+		sheet   string
+		written int
+	)
+
+	report = buildAudit("file")
+	sheets = make(map[string][][]string)
+
+	for _, group = range report.Groups {
+		rows = reviewRows(group)
+
+		for _, owner = range reviewOwners(group.DN) {
+			sheets[owner] = append(sheets[owner], rows...)
+		}
+	}
+
+	for owner = range sheets {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create review directory: %s", err.Error())
+	}
+
+	for _, owner = range owners {
+		sheet = filepath.Join(dir, pageNameInvalid.ReplaceAllString(strings.ToLower(owner), "_")+".csv")
+
+		if err = writeReviewSheet(sheet, sheets[owner]); err != nil {
+			return fmt.Errorf("failed to write review sheet %s: %s", sheet, err.Error())
+		}
+
+		glg.Infof("wrote %d membership(s) to review sheet %s", len(sheets[owner]), sheet)
+		written++
+	}
+
+	glg.Infof("exported %d review sheet(s)", written)
+
+	return nil
+}
+
+// reviewOwners returns the owners that review the memberships of a group
+func reviewOwners(dn string) []string {
+	if len(localGroups[dn].Owners) > 0 {
+		return localGroups[dn].Owners
+	}
+
+	return []string{reviewUnassigned}
+}
+
+// reviewRows returns the rows of a group within review sheets: all entries of the group file followed by the
+// memberships granted some other way
+func reviewRows(group *auditGroup) [][]string {
+	var (
+		rows    [][]string
+		file    string
+		entries []groupMember
+		i       int
+		entry   *groupMember
+		until   string
+		member  *auditGroupMember
+		local   groupOfNames
+		ok      bool
+	)
+
+	if local, ok = localGroups[group.DN]; ok {
+		file = local.file
+		entries = local.Members
+	} else {
+		file = localUnixGroups[group.DN].file
+		entries = localUnixGroups[group.DN].members
+	}
+
+	file, _ = filepath.Rel(*config.GroupDir, file)
+
+	for i = range entries {
+		entry = &entries[i]
+
+		until = ""
+		if entry.Until != nil {
+			until = formatTime(*entry.Until)
+		}
+
+		rows = append(rows, []string{
+			file, group.DN, group.Type, entry.Member, reviewUsername(entry.Member), "", until, entry.Reason,
+			entry.Ticket, entry.ApprovedBy, "", "",
+		})
+	}
+
+	for _, member = range group.Members {
+		if member.Via != "" {
+			rows = append(rows, []string{
+				file, group.DN, group.Type, "", member.Username, member.Via, member.Until, "", "", "", "", "",
+			})
+		}
+	}
+
+	return rows
+}
+
+// reviewUsername returns the username of a member entry or an empty string for nested groups
+func reviewUsername(member string) string {
+	if strings.HasPrefix(member, groupMemberPrefix) {
+		return ""
+	}
+
+	return member
+}
+
+// writeReviewSheet writes the given rows including a header to a CSV file
+func writeReviewSheet(file string, rows [][]string) error {
+	var (
+		out    *os.File
+		writer *csv.Writer
+		err    error
+	)
+
+	if out, err = os.Create(file); err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer = csv.NewWriter(out)
+
+	if err = writer.Write(reviewCSVHeader); err != nil {
+		return err
+	}
+
+	return writer.WriteAll(rows)
+}
+
+// applyReview removes all member entries marked for removal in the given review sheets from their group files
+// memberships that aren't granted by a group file entry can't be removed and are reported instead
+func applyReview(sheets []string) error {
+	var (
+		removals []reviewRemoval
+		current  []reviewRemoval
+		sheet    string
+		byFile   map[string][]reviewRemoval
+		files    []string
+		file     string
+		removal  reviewRemoval
+		removed  int
+		ok       bool
+		err      error
+	)
+
+	for _, sheet = range sheets {
+		if current, err = readReviewSheet(sheet); err != nil {
+			return fmt.Errorf("failed to read review sheet %s: %s", sheet, err.Error())
+		}
+
+		removals = append(removals, current...)
+	}
+
+	byFile = make(map[string][]reviewRemoval)
+
+	for _, removal = range removals {
+		if _, ok = byFile[removal.file]; !ok {
+			files = append(files, removal.file)
+		}
+
+		byFile[removal.file] = append(byFile[removal.file], removal)
+	}
+
+	sort.Strings(files)
+
+	for _, file = range files {
+		current, err = removeGroupMembers(filepath.Join(*config.GroupDir, file), byFile[file])
+		if err != nil {
+			return fmt.Errorf("failed to remove members from %s: %s", file, err.Error())
+		}
+
+		for _, removal = range current {
+			glg.Infof("removed %s from %s (%s)", removal.member, file, removal.sheet)
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		glg.Warnf("%d member entries were removed from group files, make sure to review and commit them", removed)
+	} else {
+		glg.Infof("no member entries to remove")
+	}
+
+	return nil
+}
+
+// readReviewSheet returns all removals of a review sheet
+// columns are found by their header, so reviewers may reorder columns or add their own
+func readReviewSheet(sheet string) ([]reviewRemoval, error) {
+	var (
+		file     *os.File
+		reader   *csv.Reader
+		header   []string
+		row      []string
+		columns  map[string]int
+		name     string
+		i        int
+		line     int
+		decision string
+		removals []reviewRemoval
+		ok       bool
+		err      error
+	)
+
+	if file, err = os.Open(sheet); err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader = csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	if header, err = reader.Read(); err != nil {
+		return nil, fmt.Errorf("failed to read header: %s", err.Error())
+	}
+
+	columns = make(map[string]int)
+	for i = range header {
+		columns[strings.TrimSpace(strings.ToLower(header[i]))] = i
+	}
+
+	for _, name = range []string{"group_file", "group", "member", "username", "via", "decision"} {
+		if _, ok = columns[name]; !ok {
+			return nil, fmt.Errorf("column %s is missing", name)
+		}
+	}
+
+	// the header is line 1
+	line = 1
+
+	for {
+		row, err = reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line++
+
+		decision = strings.ToLower(strings.TrimSpace(reviewColumn(row, columns, "decision")))
+
+		switch decision {
+		case "", "keep":
+			continue
+
+		case "remove":
+			if reviewColumn(row, columns, "member") == "" {
+				glg.Warnf("%s:%d: membership of %s in %s is granted by %s and can't be removed within the group file",
+					sheet, line, reviewColumn(row, columns, "username"), reviewColumn(row, columns, "group"),
+					reviewColumn(row, columns, "via"))
+				continue
+			}
+
+			removals = append(removals, reviewRemoval{
+				file:   filepath.Clean(reviewColumn(row, columns, "group_file")),
+				member: reviewColumn(row, columns, "member"),
+				sheet:  fmt.Sprintf("%s:%d", sheet, line),
+			})
+
+		default:
+			return nil, fmt.Errorf("line %d: unknown decision '%s', supported decisions are %s", line, decision,
+				strings.Join(reviewDecisions, ", "))
+		}
+	}
+
+	return removals, nil
+}
+
+// reviewColumn returns the value of a column of a review sheet row or an empty string if the row is too short
+func reviewColumn(row []string, columns map[string]int, name string) string {
+	if columns[name] >= len(row) {
+		return ""
+	}
+
+	return row[columns[name]]
+}
+
+// removeGroupMembers removes member entries from a group file and returns the removals that were done
+// Entries are removed line by line to keep formatting and comments of the file untouched. Entries already removed,
+// e.g. by another review sheet, are skipped.
+func removeGroupMembers(file string, removals []reviewRemoval) ([]reviewRemoval, error) {
+	var (
+		relPath string
+		info    os.FileInfo
+		content []byte
+		doc     yaml.Node
+		members *yaml.Node
+		item    *yaml.Node
+		name    string
+		lines   []string
+		items   []*yaml.Node
+		wanted  map[string]reviewRemoval
+		removal reviewRemoval
+		done    []reviewRemoval
+		ok      bool
+		start   int
+		end     int
+		err     error
+	)
+
+	// review sheets come from outside, so they must not point to files outside of group_dir
+	if relPath, err = filepath.Rel(*config.GroupDir, file); err != nil || strings.HasPrefix(relPath, "..") {
+		return nil, fmt.Errorf("file is not within group_dir")
+	}
+
+	if info, err = os.Stat(file); err != nil {
+		return nil, err
+	}
+
+	if content, err = ioutil.ReadFile(file); err != nil {
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	wanted = make(map[string]reviewRemoval)
+	for _, removal = range removals {
+		wanted[removal.member] = removal
+	}
+
+	members = yamlMappingValue(doc.Content[0], "members")
+	if members == nil || members.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+
+	if members.Style&yaml.FlowStyle != 0 {
+		return nil, fmt.Errorf("members must be written as block sequence")
+	}
+
+	for _, item = range members.Content {
+		name = item.Value
+		if item.Kind == yaml.MappingNode && yamlMappingValue(item, "member") != nil {
+			name = yamlMappingValue(item, "member").Value
+		}
+
+		if removal, ok = wanted[name]; ok {
+			items = append(items, item)
+			done = append(done, removal)
+			delete(wanted, name)
+		}
+	}
+
+	for name = range wanted {
+		glg.Warnf("%s: member %s not found in %s, it was probably removed already", wanted[name].sheet, name, relPath)
+	}
+
+	lines = strings.Split(string(content), "\n")
+
+	// remove from the bottom up to keep line numbers of the remaining entries valid
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Line > items[j].Line
+	})
+
+	for _, item = range items {
+		start = item.Line - 1
+		end = start + 1
+
+		// lines of block mappings are indented at least as far as the entry's first key
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" &&
+			len(lines[end])-len(strings.TrimLeft(lines[end], " ")) >= item.Column-1 {
+			end++
+		}
+
+		lines = append(lines[:start], lines[end:]...)
+	}
+
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	return done, ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")), info.Mode())
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kpango/glg"
+	"gopkg.in/yaml.v3"
+)
+
+// role grants memberships of a set of groups to all people objects and posixGroups listing it
+type role struct {
+	name        string     `yaml:"-"` // path of the role file relative to roles_dir
+	file        string     `yaml:"-"` // config file the role was read from
+	node        *yaml.Node `yaml:"-"` // position within file, used for error reporting
+	Description string     `yaml:"description"`
+	// Groups contains paths of group files relative to group_dir
+	Groups []string `yaml:"groups"`
+	// Sudoers contains sudo rules granted by the role; they are only shown in audits until SUDOers objects are supported
+	Sudoers []sudoersRule `yaml:"sudoers"`
+	// groupDNs contains the DNs of Groups
+	groupDNs []string `yaml:"-"`
+}
+
+// readRoleConfiguration reads all role files within roles_dir
+func readRoleConfiguration() error {
+	var (
+		err         error
+		files       []string
+		currentFile string
+		currentRole *role
+		root        *yaml.Node
+		ok          bool
+	)
+
+	if config.RolesDir == nil {
+		return nil
+	}
+
+	glg.Infof("reading role configuration files")
+
+	err = filepath.Walk(*config.RolesDir,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	for _, currentFile = range files {
+		glg.Infof("reading role config file %s", currentFile)
+
+		currentRole = new(role)
+		if root, ok = decodeYAMLFile(currentFile, currentRole); !ok {
+			continue
+		}
+
+		currentRole.file = currentFile
+		currentRole.node = root
+		currentRole.name, _ = filepath.Rel(*config.RolesDir, currentFile)
+
+		if currentRole.Description == "" {
+			currentRole.Description = "Managed by Monban"
+		}
+
+		if len(currentRole.Sudoers) > 0 {
+			glg.Warnf("%s:%d: sudo rules of role %s are only shown by audit, they are not written to LDAP",
+				currentFile, yamlKeyPosition(root, "sudoers").Line, currentRole.name)
+		}
+
+		localRoles[currentRole.name] = *currentRole
+		glg.Debugf("loaded local role %s", currentRole.name)
+	}
+
+	glg.Infof("done reading role configuration files")
+	return nil
+}
+
+// resolveRoles sets the group DNs of all roles and adds their holders as members to those groups
+// paths maps the path of every group file relative to group_dir to the DN of its group
+func resolveRoles(paths map[string]string) {
+	var (
+		name      string
+		current   role
+		i         int
+		target    string
+		ok        bool
+		dn        string
+		j         int
+		user      *posixAccount
+		names     []string
+		group     groupOfNames
+		unixGroup posixGroup
+	)
+
+	for name, current = range localRoles {
+		current.groupDNs = nil
+
+		for i = range current.Groups {
+			if target, ok = paths[filepath.Clean(current.Groups[i])]; !ok {
+				addConfigError(current.file, yamlSequenceItem(yamlMappingValue(current.node, "groups"), i),
+					"group %s doesn't exist", current.Groups[i])
+				continue
+			}
+
+			current.groupDNs = append(current.groupDNs, target)
+		}
+
+		localRoles[name] = current
+	}
+
+	for dn = range localPeople {
+		checkRoleNames(localPeople[dn].file, yamlMappingValue(localPeople[dn].node, "roles"), localPeople[dn].Roles)
+
+		for j = range localPeople[dn].Objects {
+			user = &localPeople[dn].Objects[j]
+
+			checkRoleNames(user.file, yamlMappingValue(user.node, "roles"), user.Roles)
+
+			for _, names = range [][]string{localPeople[dn].Roles, user.Roles} {
+				for _, name = range names {
+					for _, target = range localRoles[name].groupDNs {
+						// roles can grant memberships of unix groups as well
+						if unixGroup, ok = localUnixGroups[target]; ok {
+							if !containsString(unixGroup.memberUIDs, *user.UID) {
+								unixGroup.memberUIDs = append(unixGroup.memberUIDs, *user.UID)
+								localUnixGroups[target] = unixGroup
+							}
+							continue
+						}
+
+						group = localGroups[target]
+
+						if containsFold(group.memberDNs, user.dn) {
+							continue
+						}
+
+						if group.memberSources == nil {
+							group.memberSources = make(map[string]string)
+						}
+
+						group.memberDNs = append(group.memberDNs, user.dn)
+						group.memberSources[user.dn] = "role " + name
+						localGroups[target] = group
+					}
+				}
+			}
+		}
+	}
+}
+
+// checkRoleNames reports roles that don't exist within roles_dir
+// node is the sequence node of roles and only used for error positions
+func checkRoleNames(file string, node *yaml.Node, names []string) {
+	var (
+		i  int
+		ok bool
+	)
+
+	for i = range names {
+		if _, ok = localRoles[names[i]]; !ok {
+			addConfigError(file, yamlSequenceItem(node, i), "role %s doesn't exist", names[i])
+		}
+	}
+}
+
+// userRoles returns the names of all roles a user has, either directly or through its posixGroup
+func userRoles(user *posixAccount) []string {
+	var (
+		names []string
+		name  string
+		group posixGroup
+		ok    bool
+	)
+
+	if group, ok = localPeople[strings.SplitAfterN(user.dn, ",", 2)[1]]; ok {
+		names = append(names, group.Roles...)
+	}
+
+	for _, name = range user.Roles {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// roleNames returns the names of all roles in alphabetical order
+func roleNames() []string {
+	var (
+		names []string
+		name  string
+	)
+
+	for name = range localRoles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// describe returns a short description of a sudo rule used in audit output
+func (s *sudoersRule) describe() string {
+	var parts []string
+
+	if s.SudoUser != nil {
+		parts = append(parts, "sudo_user: "+*s.SudoUser)
+	}
+
+	if s.SudoHost != nil {
+		parts = append(parts, "sudo_host: "+*s.SudoHost)
+	}
+
+	if s.SudoCommand != nil {
+		parts = append(parts, "sudo_command: "+*s.SudoCommand)
+	}
+
+	if s.SudoRunAsUser != nil {
+		parts = append(parts, "sudo_run_as_user: "+*s.SudoRunAsUser)
+	}
+
+	if s.SudoOption != nil {
+		parts = append(parts, "sudo_option: "+*s.SudoOption)
+	}
+
+	return strings.Join(parts, ", ")
+}
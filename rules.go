@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// memberRuleAttributes contains the user attributes member rules can match on besides additional attributes declared
+// in the schema config
+var memberRuleAttributes = []string{
+	"username", "given_name", "surname", "display_name", "login_shell", "mail", "home_dir", "uid_number", "gid_number",
+}
+
+// memberRule selects users that become members of a group when the config is read
+// all conditions set within a rule must match
+type memberRule struct {
+	// PosixGroup is the path of a people file relative to people_dir
+	PosixGroup *string `yaml:"posix_group"`
+	// OU is a directory relative to people_dir, users of people files in sub directories are included
+	OU *string `yaml:"ou"`
+	// Attribute and Match select users with an attribute value matching a regular expression
+	Attribute *string `yaml:"attribute"`
+	Match     *string `yaml:"match"`
+	// Exclude contains usernames never selected by this rule
+	Exclude []string `yaml:"exclude"`
+
+	match *regexp.Regexp `yaml:"-"`
+}
+
+// checkMemberRules verifies the member rules of a group file
+// node is the sequence node of member_rules and only used for error positions
+func checkMemberRules(file string, node *yaml.Node, rules []memberRule) {
+	var (
+		i        int
+		j        int
+		rule     *memberRule
+		ruleNode *yaml.Node
+		err      error
+	)
+
+	for i = range rules {
+		rule = &rules[i]
+		ruleNode = yamlSequenceItem(node, i)
+
+		if rule.PosixGroup == nil && rule.OU == nil && rule.Attribute == nil {
+			addConfigError(file, ruleNode, "member rule needs at least one of posix_group, ou or attribute")
+		}
+
+		if rule.PosixGroup != nil && !peopleFileExists(*rule.PosixGroup) {
+			addConfigError(file, yamlPosition(ruleNode, "posix_group"), "people file %s doesn't exist", *rule.PosixGroup)
+		}
+
+		if rule.OU != nil && !peopleDirExists(*rule.OU) {
+			addConfigError(file, yamlPosition(ruleNode, "ou"), "there are no people files in %s", *rule.OU)
+		}
+
+		if (rule.Attribute == nil) != (rule.Match == nil) {
+			addConfigError(file, ruleNode, "attribute and match must be set together")
+		}
+
+		if rule.Attribute != nil && !containsString(memberRuleAttributes, *rule.Attribute) &&
+			schemaAttributeName(objectTypePosixAccount, *rule.Attribute) == "" {
+			addConfigError(file, yamlPosition(ruleNode, "attribute"), "unknown attribute '%s', supported are %s and attributes declared in schema",
+				*rule.Attribute, strings.Join(memberRuleAttributes, ", "))
+		}
+
+		if rule.Match != nil {
+			if rule.match, err = regexp.Compile(*rule.Match); err != nil {
+				addConfigError(file, yamlPosition(ruleNode, "match"), "invalid regular expression: %s", err.Error())
+			}
+		}
+
+		for j = range rule.Exclude {
+			if findUser(rule.Exclude[j]) == nil {
+				addConfigError(file, yamlSequenceItem(yamlMappingValue(ruleNode, "exclude"), j),
+					"excluded uid %s doesn't exist as user object", rule.Exclude[j])
+			}
+		}
+	}
+}
+
+// inScope returns true if the user is within the people files selected by posix_group and ou
+func (r *memberRule) inScope(user *posixAccount) bool {
+	var relPath string
+
+	relPath, _ = filepath.Rel(*config.PeopleDir, user.file)
+
+	if r.PosixGroup != nil && relPath != filepath.Clean(*r.PosixGroup) {
+		return false
+	}
+
+	if r.OU != nil && !strings.HasPrefix(filepath.Dir(relPath)+"/", filepath.Clean(*r.OU)+"/") {
+		return false
+	}
+
+	return true
+}
+
+// excludes returns true if the user is on the exclusion list of the rule
+func (r *memberRule) excludes(user *posixAccount) bool {
+	return containsString(r.Exclude, *user.UID)
+}
+
+// matches returns true if any value of the rule's attribute matches its regular expression
+func (r *memberRule) matches(user *posixAccount) bool {
+	var value string
+
+	if r.Attribute == nil {
+		return true
+	}
+
+	// invalid expressions have already been reported
+	if r.match == nil {
+		return false
+	}
+
+	for _, value = range memberRuleValues(user, *r.Attribute) {
+		if r.match.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describe returns a short description of the rule used in diff output
+func (r *memberRule) describe(index int) string {
+	var conditions []string
+
+	if r.PosixGroup != nil {
+		conditions = append(conditions, "posix_group "+*r.PosixGroup)
+	}
+
+	if r.OU != nil {
+		conditions = append(conditions, "ou "+*r.OU)
+	}
+
+	if r.Attribute != nil && r.Match != nil {
+		conditions = append(conditions, fmt.Sprintf("%s =~ %s", *r.Attribute, *r.Match))
+	}
+
+	return fmt.Sprintf("member_rules[%d] (%s)", index, strings.Join(conditions, ", "))
+}
+
+// expandMemberRules adds all users selected by the member rules of a group to its member DNs
+// the rule that selected a member is recorded in memberSources; static members are never overwritten and users whose
+// entry in members is outside of its time frame (from/until) aren't selected by rules either
+func expandMemberRules(group *groupOfNames) {
+	var (
+		i    int
+		dn   string
+		j    int
+		user *posixAccount
+	)
+
+	if len(group.MemberRules) == 0 {
+		return
+	}
+
+	group.memberSources = make(map[string]string)
+
+	for i = range group.MemberRules {
+		for dn = range localPeople {
+			for j = range localPeople[dn].Objects {
+				user = &localPeople[dn].Objects[j]
+
+				if !group.MemberRules[i].inScope(user) || group.MemberRules[i].excludes(user) ||
+					!group.MemberRules[i].matches(user) {
+					continue
+				}
+
+				if containsFold(group.memberDNs, user.dn) || inactiveStaticMember(group, user) {
+					continue
+				}
+
+				group.memberDNs = append(group.memberDNs, user.dn)
+				group.memberSources[user.dn] = group.MemberRules[i].describe(i)
+			}
+		}
+	}
+}
+
+// inactiveStaticMember returns true if the user is listed in the members of the group with a time frame that isn't
+// active right now
+func inactiveStaticMember(group *groupOfNames, user *posixAccount) bool {
+	var i int
+
+	for i = range group.Members {
+		if group.Members[i].Member == *user.UID && !group.Members[i].active(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// memberRuleRejection explains why a user possibly selected by a group's rules before isn't a member anymore
+// returns an empty string if no rule covers the user
+func memberRuleRejection(group *groupOfNames, memberDN string) string {
+	var (
+		user    *posixAccount
+		i       int
+		reasons []string
+	)
+
+	if user = findUserByDN(memberDN); user == nil {
+		return ""
+	}
+
+	for i = range group.MemberRules {
+		if !group.MemberRules[i].inScope(user) {
+			continue
+		}
+
+		if group.MemberRules[i].excludes(user) {
+			reasons = append(reasons, "excluded by "+group.MemberRules[i].describe(i))
+			continue
+		}
+
+		if !group.MemberRules[i].matches(user) {
+			reasons = append(reasons, "not matched by "+group.MemberRules[i].describe(i))
+		}
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+// memberRuleValues returns the values of a user attribute as used by member rules
+func memberRuleValues(user *posixAccount, name string) []string {
+	var value *string
+
+	switch name {
+	case "username":
+		value = user.UID
+	case "given_name":
+		value = user.GivenName
+	case "surname":
+		value = user.Surname
+	case "display_name":
+		value = user.DisplayName
+	case "login_shell":
+		value = user.LoginShell
+	case "mail":
+		value = user.Mail
+	case "home_dir":
+		value = user.HomeDir
+	case "uid_number":
+		if user.UIDNumber != nil {
+			return []string{strconv.Itoa(*user.UIDNumber)}
+		}
+	case "gid_number":
+		if user.GIDNumber != nil {
+			return []string{strconv.Itoa(*user.GIDNumber)}
+		}
+	default:
+		return user.Attributes[schemaAttributeName(objectTypePosixAccount, name)]
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	return []string{*value}
+}
+
+// peopleFileExists returns true if a people file with the given path relative to people_dir was read
+func peopleFileExists(path string) bool {
+	var (
+		dn      string
+		relPath string
+	)
+
+	for dn = range localPeople {
+		relPath, _ = filepath.Rel(*config.PeopleDir, localPeople[dn].file)
+		if relPath == filepath.Clean(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peopleDirExists returns true if any people file was read from the given directory relative to people_dir or one of
+// its sub directories
+func peopleDirExists(path string) bool {
+	var (
+		dn      string
+		relPath string
+	)
+
+	for dn = range localPeople {
+		relPath, _ = filepath.Rel(*config.PeopleDir, localPeople[dn].file)
+		if strings.HasPrefix(filepath.Dir(relPath)+"/", filepath.Clean(path)+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findUser returns the local user object with the given username or nil
+func findUser(uid string) *posixAccount {
+	var (
+		dn string
+		i  int
+	)
+
+	for dn = range localPeople {
+		for i = range localPeople[dn].Objects {
+			if *localPeople[dn].Objects[i].UID == uid {
+				return &localPeople[dn].Objects[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// findUserByDN returns the local user object with the given DN or nil
+func findUserByDN(dn string) *posixAccount {
+	var (
+		groupDN string
+		i       int
+	)
+
+	for groupDN = range localPeople {
+		for i = range localPeople[groupDN].Objects {
+			if strings.EqualFold(localPeople[groupDN].Objects[i].dn, dn) {
+				return &localPeople[groupDN].Objects[i]
+			}
+		}
+	}
+
+	return nil
+}
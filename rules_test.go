@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestExpandMemberRulesPrecedence verifies static member entries outside of their time frame aren't added back by a
+// member rule selecting the same user
+func TestExpandMemberRulesPrecedence(t *testing.T) {
+	var (
+		dn        = "cn=devops,ou=people,dc=my-domain,dc=com"
+		peopleDir = "people"
+		devops    = "devops"
+		today     = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		expired   = today.AddDate(0, 0, -1)
+		expiredDN string
+		ruleDN    string
+		users     []posixAccount
+		group     groupOfNames
+	)
+
+	defer setupCompare(today)()
+	config.PeopleDir = &peopleDir
+
+	users = []posixAccount{testAccount("johndoe"), testAccount("janedoe")}
+	users[0].file = "people/devops"
+	users[1].file = "people/devops"
+	expiredDN = users[0].dn
+	ruleDN = users[1].dn
+
+	localPeople = map[string]posixGroup{dn: posixGroup{dn: dn, Objects: users}}
+	defer func() {
+		localPeople = nil
+	}()
+
+	group = groupOfNames{
+		Members:     []groupMember{{Member: "johndoe", Until: &expired}},
+		MemberRules: []memberRule{{PosixGroup: &devops}},
+	}
+
+	expandMemberRules(&group)
+
+	if containsFold(group.memberDNs, expiredDN) {
+		t.Errorf("expected expired member %s not to be added by member rule", expiredDN)
+	}
+
+	if !containsFold(group.memberDNs, ruleDN) || group.memberSources[ruleDN] == "" {
+		t.Errorf("expected %s to be added by member rule", ruleDN)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultSchemaProfile is used when the main config doesn't name a profile
+const defaultSchemaProfile = "openldap-nis"
+
+// schemaProfile describes the object classes and attribute names used for objects created by Monban
+type schemaProfile struct {
+	// objectClasses contains the object classes of each object type, see objectClassSets
+	objectClasses map[string][]string
+	// attributeNames maps standard attribute names (e.g. sshPublicKey) to the names used by the LDAP server
+	attributeNames map[string]string
+	// lockValue is the value of pwdAccountLockedTime (or the attribute it is mapped to) of locked accounts, defaults
+	// to permanentLockTime
+	lockValue string
+}
+
+var (
+	// schemaProfiles contains all built-in profiles
+	schemaProfiles = map[string]*schemaProfile{
+		// OpenLDAP with nis and ssh schema
+		"openldap-nis": &schemaProfile{
+			objectClasses: map[string][]string{
+				"posixAccount":       []string{"inetOrgPerson", "organizationalPerson", "person", "posixAccount", "shadowAccount", "top"},
+				"sshPublicKey":       []string{"ldapPublicKey"},
+				"posixGroup":         []string{"posixGroup", "top"},
+				"groupOfNames":       []string{"groupOfNames", "top"},
+				"organizationalUnit": []string{"organizationalUnit", "top"},
+			},
+		},
+		// OpenLDAP with rfc2307bis and ssh schema; posixGroup is auxiliary and needs a structural object class
+		"openldap-rfc2307bis": &schemaProfile{
+			objectClasses: map[string][]string{
+				"posixAccount":       []string{"inetOrgPerson", "organizationalPerson", "person", "posixAccount", "shadowAccount", "top"},
+				"sshPublicKey":       []string{"ldapPublicKey"},
+				"posixGroup":         []string{"groupOfNames", "posixGroup", "top"},
+				"groupOfNames":       []string{"groupOfNames", "top"},
+				"organizationalUnit": []string{"organizationalUnit", "top"},
+			},
+		},
+		// 389 Directory Server which ships SSH public keys as part of nsAccount and locks accounts with nsAccountLock
+		"389ds": &schemaProfile{
+			objectClasses: map[string][]string{
+				"posixAccount":       []string{"inetOrgPerson", "organizationalPerson", "person", "posixAccount", "shadowAccount", "top"},
+				"sshPublicKey":       []string{"nsAccount"},
+				"posixGroup":         []string{"groupOfNames", "posixGroup", "top"},
+				"groupOfNames":       []string{"groupOfNames", "top"},
+				"organizationalUnit": []string{"organizationalUnit", "top"},
+			},
+			attributeNames: map[string]string{
+				"sshPublicKey":         "nsSshPublicKey",
+				"pwdAccountLockedTime": "nsAccountLock",
+			},
+			lockValue: "TRUE",
+		},
+	}
+
+	// objectClassSets contains the names of all object class sets and the object class each set must contain to
+	// identify objects when reading them from LDAP
+	// sshPublicKey is added to posixAccount objects when enable_ssh_public_keys is true
+	objectClassSets = map[string]string{
+		"posixAccount":       "posixAccount",
+		"sshPublicKey":       "",
+		"posixGroup":         "posixGroup",
+		"groupOfNames":       "groupOfNames",
+		"organizationalUnit": "organizationalUnit",
+	}
+
+	// mappableAttributes contains all attributes whose name can be changed by profiles or the attribute_names config
+	mappableAttributes = []string{
+		"uidNumber", "gidNumber", "givenName", "sn", "displayName", "loginShell", "mail", "homeDirectory",
+		"userPassword", "sshPublicKey", "description", "memberUid", "shadowExpire", "pwdAccountLockedTime",
+	}
+
+	// activeSchema is the profile in use including all overrides of the main config
+	activeSchema *schemaProfile
+)
+
+// initSchema selects the configured schema profile and applies object classes and attribute names of the main config
+// node is the mapping node of schema and only used for error positions
+func initSchema(node *yaml.Node) {
+	var (
+		profile *schemaProfile
+		name    string
+		ok      bool
+		set     string
+		classes []string
+		from    string
+		to      string
+		keys    []string
+	)
+
+	name = defaultSchemaProfile
+	if config.Schema.Profile != nil {
+		name = *config.Schema.Profile
+	}
+
+	if profile, ok = schemaProfiles[name]; !ok {
+		addConfigError(configFile, yamlPosition(node, "profile"), "unknown schema profile '%s', supported profiles are %s",
+			name, strings.Join(schemaProfileNames(), ", "))
+		profile = schemaProfiles[defaultSchemaProfile]
+	}
+
+	// copy the profile to never change the built-in one
+	activeSchema = &schemaProfile{
+		objectClasses:  make(map[string][]string),
+		attributeNames: make(map[string]string),
+		lockValue:      profile.lockValue,
+	}
+
+	for set, classes = range profile.objectClasses {
+		activeSchema.objectClasses[set] = classes
+	}
+
+	for from, to = range profile.attributeNames {
+		activeSchema.attributeNames[from] = to
+	}
+
+	// report errors in a stable order
+	for set = range config.Schema.ObjectClasses {
+		keys = append(keys, set)
+	}
+	sort.Strings(keys)
+
+	for _, set = range keys {
+		classes = config.Schema.ObjectClasses[set]
+
+		if _, ok = objectClassSets[set]; !ok {
+			addConfigError(configFile, yamlKeyPosition(yamlMappingValue(node, "object_classes"), set),
+				"unknown object class set '%s', supported sets are posixAccount, sshPublicKey, posixGroup, groupOfNames, organizationalUnit",
+				set)
+			continue
+		}
+
+		if objectClassSets[set] != "" && !containsString(classes, objectClassSets[set]) {
+			addConfigError(configFile, yamlPosition(yamlMappingValue(node, "object_classes"), set),
+				"object classes of %s must contain %s", set, objectClassSets[set])
+			continue
+		}
+
+		activeSchema.objectClasses[set] = classes
+	}
+
+	keys = nil
+	for from = range config.Schema.AttributeNames {
+		keys = append(keys, from)
+	}
+	sort.Strings(keys)
+
+	for _, from = range keys {
+		to = config.Schema.AttributeNames[from]
+
+		if !containsString(mappableAttributes, from) {
+			addConfigError(configFile, yamlKeyPosition(yamlMappingValue(node, "attribute_names"), from),
+				"name of attribute '%s' can't be changed, supported attributes are %s", from, strings.Join(mappableAttributes, ", "))
+			continue
+		}
+
+		if !attributeNameRegex.MatchString(to) {
+			addConfigError(configFile, yamlPosition(yamlMappingValue(node, "attribute_names"), from),
+				"invalid attribute name '%s'", to)
+			continue
+		}
+
+		activeSchema.attributeNames[from] = to
+	}
+}
+
+// objectClasses returns the object classes of an object class set
+// posixAccount objects also get the sshPublicKey object classes when SSH public keys are enabled
+func objectClasses(set string) []string {
+	var classes []string
+
+	classes = append(classes, activeSchema.objectClasses[set]...)
+
+	if set == "posixAccount" && *config.EnableSSHPublicKeys {
+		classes = append(classes, activeSchema.objectClasses["sshPublicKey"]...)
+	}
+
+	return classes
+}
+
+// hybridGroupsSupported returns true if groups can be posixGroup and groupOfNames at the same time
+// this requires posixGroup to be an auxiliary object class (rfc2307bis) which is what profiles using groupOfNames for
+// posixGroup objects rely on as well
+func hybridGroupsSupported() bool {
+	return containsString(activeSchema.objectClasses["posixGroup"], "groupOfNames")
+}
+
+// hybridGroupClasses returns the object classes of groups that are posixGroup and groupOfNames at the same time
+func hybridGroupClasses() []string {
+	return append(objectClasses("groupOfNames"), posixGroupExtraClasses()...)
+}
+
+// posixGroupExtraClasses returns the object classes of the posixGroup set a groupOfNames lacks, which are added or
+// removed when gid_number of an existing group is set or removed
+func posixGroupExtraClasses() []string {
+	var (
+		classes []string
+		class   string
+	)
+
+	for _, class = range objectClasses("posixGroup") {
+		if !containsString(objectClasses("groupOfNames"), class) {
+			classes = append(classes, class)
+		}
+	}
+
+	return classes
+}
+
+// ldapAttribute returns the name the LDAP server uses for a standard attribute
+func ldapAttribute(name string) string {
+	var (
+		mapped string
+		ok     bool
+	)
+
+	if mapped, ok = activeSchema.attributeNames[name]; ok {
+		return mapped
+	}
+
+	return name
+}
+
+// accountLockValue returns the value of the lock attribute (pwdAccountLockedTime) of permanently locked accounts
+func accountLockValue() string {
+	if activeSchema.lockValue != "" {
+		return activeSchema.lockValue
+	}
+
+	return permanentLockTime
+}
+
+// containsString returns true if list contains s
+func containsString(list []string, s string) bool {
+	var item string
+
+	for _, item = range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// schemaProfileNames returns the names of all built-in schema profiles in alphabetical order
+func schemaProfileNames() []string {
+	var (
+		names []string
+		name  string
+	)
+
+	for name = range schemaProfiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
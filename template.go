@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// templatePlaceholders maps the short placeholders of default templates to the template variables they stand for
+	templatePlaceholders = strings.NewReplacer(
+		"%%", "%",
+		"%u", "{{ .username }}",
+		"%g", "{{ .given_name }}",
+		"%l", "{{ .surname }}",
+		"%G", "{{ .group }}",
+		"%o", "{{ .ou_path }}",
+	)
+
+	// mailPlaceholders lowercases %u, %g and %l within mail defaults as mail addresses generated from them have always
+	// been lowercased; other placeholders and %% are left to templatePlaceholders
+	mailPlaceholders = strings.NewReplacer(
+		"%%", "%%",
+		"%u", "{{ lower .username }}",
+		"%g", "{{ lower .given_name }}",
+		"%l", "{{ lower .surname }}",
+	)
+
+	// templateCache holds all templates parsed so far indexed by their source
+	templateCache = make(map[string]*template.Template)
+
+	// regexCache holds all regular expressions used by regexReplace so far indexed by their source
+	regexCache = make(map[string]*regexp.Regexp)
+
+	// transliterator replaces characters as configured, see initTransliteration
+	transliterator *strings.Replacer
+
+	// templateFuncs contains all functions that can be used within default templates
+	templateFuncs = template.FuncMap{
+		"lower":         strings.ToLower,
+		"upper":         strings.ToUpper,
+		"trim":          strings.TrimSpace,
+		"first":         templateFirst,
+		"truncate":      templateTruncate,
+		"transliterate": transliterate,
+		"regexReplace":  templateRegexReplace,
+	}
+)
+
+// parseTemplate parses a default template
+// short placeholders (e.g. %u) are replaced by their template variables before parsing
+func parseTemplate(text string) (*template.Template, error) {
+	var (
+		tmpl *template.Template
+		ok   bool
+		err  error
+	)
+
+	if tmpl, ok = templateCache[text]; ok {
+		return tmpl, nil
+	}
+
+	tmpl, err = template.New("default").
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(templatePlaceholders.Replace(text))
+	if err != nil {
+		return nil, err
+	}
+
+	templateCache[text] = tmpl
+
+	return tmpl, nil
+}
+
+// defaultTemplate returns the template of an attribute default as it is parsed
+func defaultTemplate(name string, text string) string {
+	if name == "mail" {
+		return mailPlaceholders.Replace(text)
+	}
+
+	return text
+}
+
+// renderTemplate renders a default template with the given variables
+func renderTemplate(text string, data map[string]interface{}) (string, error) {
+	var (
+		tmpl *template.Template
+		buf  bytes.Buffer
+		err  error
+	)
+
+	if tmpl, err = parseTemplate(text); err != nil {
+		return "", err
+	}
+
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return norm.NFC.String(buf.String()), nil
+}
+
+// templateData returns the variables available to default templates of a user object within its posixGroup
+// uid_number and gid_number are only available when they are known at the time the config is read
+func templateData(group *posixGroup, user *posixAccount) map[string]interface{} {
+	var (
+		data    map[string]interface{}
+		relPath string
+	)
+
+	// names can be written in different unicode forms (e.g. ü as one or two code points), templates always get NFC
+	data = map[string]interface{}{
+		"given_name": norm.NFC.String(*user.GivenName),
+		"surname":    norm.NFC.String(*user.Surname),
+		"group":      norm.NFC.String(group.CN),
+	}
+
+	// username is unknown while it is generated
+	if user.UID != nil {
+		data["username"] = norm.NFC.String(*user.UID)
+	}
+
+	// OU path is the directory of the people file relative to people_dir
+	relPath, _ = filepath.Rel(*config.PeopleDir, filepath.Dir(group.file))
+	if relPath == "." {
+		relPath = ""
+	}
+	data["ou_path"] = relPath
+
+	if user.UIDNumber != nil {
+		data["uid_number"] = *user.UIDNumber
+	}
+
+	if user.GIDNumber != nil {
+		data["gid_number"] = *user.GIDNumber
+	}
+
+	return data
+}
+
+// groupTemplateData returns the variables available to templated attributes of a groupOfNames
+func groupTemplateData(group *groupOfNames) map[string]interface{} {
+	return map[string]interface{}{
+		"group": norm.NFC.String(group.CN),
+	}
+}
+
+// templateFirst returns the first letter of s
+func templateFirst(s string) string {
+	var r rune
+
+	if s == "" {
+		return ""
+	}
+
+	r, _ = utf8.DecodeRuneInString(s)
+
+	return string(r)
+}
+
+// templateTruncate returns the first n letters of s
+func templateTruncate(n int, s string) string {
+	var runes []rune
+
+	runes = []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n])
+}
+
+// templateRegexReplace replaces all matches of the regular expression pattern in s with replacement
+// replacement can reference submatches with $1, ${name} etc.
+func templateRegexReplace(pattern string, replacement string, s string) (string, error) {
+	var (
+		re  *regexp.Regexp
+		ok  bool
+		err error
+	)
+
+	if re, ok = regexCache[pattern]; !ok {
+		if re, err = regexp.Compile(pattern); err != nil {
+			return "", fmt.Errorf("invalid regular expression '%s': %s", pattern, err.Error())
+		}
+
+		regexCache[pattern] = re
+	}
+
+	return re.ReplaceAllString(s, replacement), nil
+}
+
+// initTransliteration builds the transliteration rules from the built-in table and the configured rules
+// configured rules take precedence over built-in ones
+func initTransliteration() {
+	var (
+		pairs []string
+		from  string
+		r     rune
+		keys  []string
+	)
+
+	// sort custom rules to always get the same result when rules overlap
+	for from = range config.Transliteration.Rules {
+		keys = append(keys, from)
+	}
+	sort.Strings(keys)
+
+	for _, from = range keys {
+		pairs = append(pairs, from, config.Transliteration.Rules[from])
+	}
+
+	for r = range transliterations {
+		pairs = append(pairs, string(r), transliterations[r])
+	}
+
+	transliterator = strings.NewReplacer(pairs...)
+}
+
+// transliterate replaces non-ASCII letters with their common ASCII representation (e.g. ä => ae, ß => ss, é => e)
+// characters without a known representation are kept as is
+func transliterate(s string) string {
+	if transliterator == nil {
+		initTransliteration()
+	}
+
+	s = transliterator.Replace(norm.NFC.String(s))
+
+	if config.Transliteration.StripDiacritics == nil || *config.Transliteration.StripDiacritics {
+		s = stripDiacritics(s)
+	}
+
+	return s
+}
+
+// stripDiacritics removes accents and other combining marks from s (é => e)
+func stripDiacritics(s string) string {
+	var (
+		b strings.Builder
+		r rune
+	)
+
+	// decompose characters into base letter and combining marks and drop the marks
+	for _, r = range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return norm.NFC.String(b.String())
+}
+
+// transliterateAttribute returns true if the value of a templated attribute is always to be transliterated
+func transliterateAttribute(name string) bool {
+	var attribute string
+
+	for _, attribute = range config.Transliteration.Attributes {
+		if attribute == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transliterations contains the ASCII representation of letters that are not just a base letter with diacritics
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'Ä': "Ae", 'Ö': "Oe", 'Ü': "Ue", 'ß': "ss", 'ẞ': "SS",
+	'æ': "ae", 'Æ': "Ae", 'ø': "oe", 'Ø': "Oe", 'å': "aa", 'Å': "Aa", 'œ': "oe", 'Œ': "Oe",
+	'þ': "th", 'Þ': "Th", 'ð': "d", 'Ð': "D", 'đ': "d", 'Đ': "D", 'ł': "l", 'Ł': "L", 'ı': "i",
+	'ħ': "h", 'Ħ': "H", 'ŋ': "ng", 'Ŋ': "Ng", 'ĳ': "ij", 'Ĳ': "Ij",
+}
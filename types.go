@@ -2,6 +2,8 @@ package main
 
 import (
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // configuration contains general configuration data
@@ -12,29 +14,89 @@ type configuration struct {
 	EnableSSHPublicKeys *bool   `yaml:"enable_ssh_public_keys"`
 	GroupDir            *string `yaml:"group_dir"`
 	PeopleDir           *string `yaml:"people_dir"`
-	RootDN              *string `yaml:"root_dn"`
-	PeopleRDN           *string `yaml:"people_rdn"`
-	GroupRDN            *string `yaml:"group_rdn"`
-	GenerateUID         bool    `yaml:"generate_uid"`
-	MinUID              int     `yaml:"min_uid"`
-	MaxUID              int     `yaml:"max_uid"`
+	RolesDir            *string `yaml:"roles_dir"`
+	// memberships expiring within this number of days are listed by validate and audit; default: 14
+	ExpiryWarningDays *int    `yaml:"membership_expiry_warning_days"`
+	RootDN            *string `yaml:"root_dn"`
+	PeopleRDN         *string `yaml:"people_rdn"`
+	GroupRDN          *string `yaml:"group_rdn"`
+	GenerateUID       bool    `yaml:"generate_uid"`
+	MinUID            int     `yaml:"min_uid"`
+	MaxUID            int     `yaml:"max_uid"`
 	// contains the default values (or patterns) used when an object doesn't explicitly defines them
-	Defaults struct {
-		DisplayName  *string `yaml:"display_name"`
-		LoginShell   *string `yaml:"login_shell"`
-		Mail         *string `yaml:"mail"`
-		HomeDir      *string `yaml:"home_dir"`
-		UserPassword *string `yaml:"user_password"`
-	} `yaml:"defaults"`
+	Defaults defaults `yaml:"defaults"`
+	// policies of people object attributes (attribute => managed, set_on_create or ignore); default: managed
+	AttributePolicy map[string]string `yaml:"attribute_policy"`
+	// rules for user_password values of people objects and defaults, only checked if password_policy is set
+	PasswordPolicy struct {
+		// enabled is true if password_policy is set in the main config
+		enabled bool
+		// schemes user_password values may use, e.g. SSHA512, ARGON2 or SASL; default: all schemes
+		AllowedSchemes []string `yaml:"allowed_schemes"`
+		// allow user_password values without scheme; default: false
+		AllowCleartext bool `yaml:"allow_cleartext"`
+	} `yaml:"password_policy"`
+	// controls how non-ASCII characters are replaced in templated attributes
+	Transliteration struct {
+		// templated attributes whose values are always transliterated
+		Attributes []string `yaml:"attributes"`
+		// custom replacements taking precedence over the built-in ones
+		Rules map[string]string `yaml:"rules"`
+		// remove remaining accents and other diacritics (é => e); default: true
+		StripDiacritics *bool `yaml:"strip_diacritics"`
+	} `yaml:"transliteration"`
+	// describes the LDAP schema Monban works with
+	Schema struct {
+		// name of a built-in schema profile; default: openldap-nis
+		Profile *string `yaml:"profile"`
+		// object classes per object class set overriding the profile
+		ObjectClasses map[string][]string `yaml:"object_classes"`
+		// LDAP attribute names overriding the profile (standard name => server name)
+		AttributeNames map[string]string `yaml:"attribute_names"`
+		// additional attributes managed by Monban indexed by their LDAP name
+		Attributes map[string]*attributeSchema `yaml:"attributes"`
+	} `yaml:"schema"`
+}
+
+// attributeSchema describes an additional LDAP attribute that can be set on objects
+type attributeSchema struct {
+	// object types the attribute can be set on (posixAccount, groupOfNames); default: posixAccount
+	ObjectTypes []string `yaml:"object_types"`
+	// attribute can have more than one value
+	MultiValued bool `yaml:"multi_valued"`
+	// values are templates rendered with the same variables as defaults
+	Templated bool `yaml:"templated"`
+	// objectTypes contains the parsed ObjectTypes
+	objectTypes map[int]bool
+}
+
+// defaults contains the default values (or templates) used when an object doesn't explicitly define them
+// they can be set in the main config, per directory within people_dir and per people file
+type defaults struct {
+	Username     *string `yaml:"username"`
+	DisplayName  *string `yaml:"display_name"`
+	LoginShell   *string `yaml:"login_shell"`
+	Mail         *string `yaml:"mail"`
+	HomeDir      *string `yaml:"home_dir"`
+	UserPassword *string `yaml:"user_password"`
 }
 
 // posixGroup contains information about a LDAP user group object
 type posixGroup struct {
 	dn          string         `yaml:"-"`
+	file        string         `yaml:"-"` // config file the group was read from
+	node        *yaml.Node     `yaml:"-"` // position within file, used for error reporting
 	CN          string         `yaml:"cn"`
 	GIDNumber   *int           `yaml:"gid_number"`
 	Description string         `yaml:"description"`
+	Defaults    *defaults      `yaml:"defaults"`
 	Objects     []posixAccount `yaml:"objects"`
+	// Roles are granted to all objects of a people file
+	Roles []string `yaml:"roles"`
+	// members contains the member entries of unix groups defined in group_dir
+	members []groupMember `yaml:"-"`
+	// memberUIDs contains the usernames of active members (memberUid) of unix groups defined in group_dir
+	memberUIDs []string `yaml:"-"`
 }
 
 // posixAccount represents a LDAP user object
@@ -44,26 +106,70 @@ type posixGroup struct {
 // change task: nil ptr means no change of that attribute
 // delete task: only CN is set
 type posixAccount struct {
-	dn           string  `yaml:"-"`
-	UID          *string `yaml:"username"` // also CN
-	UIDNumber    *int    `yaml:"uid_number"`
-	GIDNumber    *int    `yaml:"gid_number"`
-	GivenName    *string `yaml:"given_name"`
-	Surname      *string `yaml:"surname"`
-	DisplayName  *string `yaml:"display_name"`
-	LoginShell   *string `yaml:"login_shell"`
-	Mail         *string `yaml:"mail"`
-	SSHPublicKey *string `yaml:"ssh_public_key"`
-	HomeDir      *string `yaml:"home_dir"`
-	UserPassword *string `yaml:"user_password"`
+	dn           string     `yaml:"-"`
+	file         string     `yaml:"-"`        // config file the object was read from
+	node         *yaml.Node `yaml:"-"`        // position within file, used for error reporting
+	UID          *string    `yaml:"username"` // also CN
+	UIDNumber    *int       `yaml:"uid_number"`
+	GIDNumber    *int       `yaml:"gid_number"`
+	GivenName    *string    `yaml:"given_name"`
+	Surname      *string    `yaml:"surname"`
+	DisplayName  *string    `yaml:"display_name"`
+	LoginShell   *string    `yaml:"login_shell"`
+	Mail         *string    `yaml:"mail"`
+	SSHPublicKey *string    `yaml:"ssh_public_key"`
+	HomeDir      *string    `yaml:"home_dir"`
+	UserPassword *string    `yaml:"user_password"`
+	// Expires is the first moment the account is disabled, written to shadowExpire (update task: zero time deletes)
+	Expires *time.Time `yaml:"expires"`
+	// Disabled prefixes userPassword with lockedPasswordPrefix, expired accounts are disabled as well
+	Disabled *bool `yaml:"disabled"`
+	// Locked sets pwdAccountLockedTime, which requires the ppolicy overlay (nsAccountLock with the 389ds profile)
+	Locked *bool `yaml:"locked"`
+	// StartDate delays provisioning, until then the account and its memberships are treated as not present
+	StartDate *time.Time `yaml:"start_date"`
+	// additional attributes as declared in the schema config
+	Attributes map[string]attributeValues `yaml:"attributes"`
+	// Roles contains names of role files relative to roles_dir
+	Roles []string `yaml:"roles"`
+	// AttributePolicy overrides the attribute policy of the main config for this object
+	AttributePolicy map[string]string `yaml:"attribute_policy"`
+	// sources contains where defaulted attributes got their value from (attribute name => defaults layer)
+	sources map[string]string `yaml:"-"`
 }
 
 // groupOfNames contains information about a groups with members
 type groupOfNames struct {
-	dn          string   `yaml:"-"` // internal only
-	CN          string   `yaml:"cn"`
-	Description string   `yaml:"description"`
-	Members     []string `yaml:"members"`
+	dn          string     `yaml:"-"` // internal only
+	file        string     `yaml:"-"` // config file the group was read from
+	node        *yaml.Node `yaml:"-"` // position within file, used for error reporting
+	CN          string     `yaml:"cn"`
+	Description string     `yaml:"description"`
+	// Type is either groupOfNames (default) or posixGroup for unix groups with memberUid members
+	Type string `yaml:"type"`
+	// GIDNumber makes the group a posixGroup as well (rfc2307bis only)
+	GIDNumber *int          `yaml:"gid_number"`
+	Members   []groupMember `yaml:"members"`
+	// Owners contains usernames of the people responsible for the group, written to owner as user DNs
+	Owners []string `yaml:"owners"`
+	// MemberPolicy contains rules for entries of Members
+	MemberPolicy *memberPolicy `yaml:"member_policy"`
+	// MemberRules select users that become members in addition to Members
+	MemberRules []memberRule `yaml:"member_rules"`
+	// memberDNs contains the DNs of all members, users as well as nested groups
+	memberDNs []string `yaml:"-"`
+	// memberSources contains the member rule or role that added a member (member DN => rule or "role <name>"), static
+	// members are not included
+	memberSources map[string]string `yaml:"-"`
+	// ownerDNs contains the DNs of Owners
+	ownerDNs []string `yaml:"-"`
+	// additional attributes as declared in the schema config
+	Attributes map[string]attributeValues `yaml:"attributes"`
+	// only used for update tasks when an existing group becomes or stops being a posixGroup
+	addPosixGroup    bool `yaml:"-"`
+	deletePosixGroup bool `yaml:"-"`
+	// only used for update tasks, ownerDNs replace the owners in LDAP (none deletes them)
+	updateOwners bool `yaml:"-"`
 }
 
 // actionTask defines a task to execute against a ldap target
@@ -76,14 +182,17 @@ type actionTask struct {
 	// objectType == objectTypePosixAccount
 	//		data is posixAccount struct
 	// objectType == objectTypePosixGroup
-	//    data is posixGroup struct
+	//    create, delete, update: data is posixGroup struct
+	//    add or delete member: (string) username to add or remove from memberUid
 	// objectType == objectTypeGroupOfNames
 	//    create, delete, update: data is groupOfNames struct
-	//    add or delete member: (string) value to add or remove
+	//    add or delete member: (string) DN of the user or nested group to add or remove
 	// objectType == objectTypeOrganisationalUnit
 	//		create: organizationalUnit
 	// 		delete: nil (but dn set above)
 	data interface{}
+	// note explains why the task was created (e.g. the member rule that selected a member) and is shown by diff
+	note string
 }
 
 // sudoersRule defines a LDAP SUDOers object
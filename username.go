@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kpango/glg"
+	"gopkg.in/yaml.v3"
+)
+
+// usernameRegex defines which usernames are accepted when they are generated
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// generateUsername sets the username of a user object from the first layer of defaults that defines a username pattern
+// When the username is already taken a numeric suffix is added (jdoe, jdoe2, jdoe3, ...). As objects are processed in
+// file order and all explicitly set usernames are taken before, the result is deterministic. Returns false when no
+// username could be generated.
+func generateUsername(user *posixAccount, group *posixGroup, layers []*defaultsLayer, taken map[string]bool) bool {
+	var (
+		layer     *defaultsLayer
+		tmpl      *string
+		rendered  string
+		candidate string
+		i         int
+		err       error
+	)
+
+	for _, layer = range layers {
+		if tmpl = layer.values.get("username"); tmpl != nil {
+			break
+		}
+	}
+
+	if tmpl == nil {
+		addConfigError(user.file, user.node, "username not set in object and no default is defined")
+		return false
+	}
+
+	// broken templates are already reported when reading the defaults
+	if _, err = parseTemplate(*tmpl); err != nil {
+		return false
+	}
+
+	if rendered, err = renderTemplate(*tmpl, templateData(group, user)); err != nil {
+		addConfigError(user.file, user.node, "failed to generate username from default template of %s: %s", layer.name,
+			err.Error())
+		return false
+	}
+
+	if transliterateAttribute("username") {
+		rendered = transliterate(rendered)
+	}
+
+	if !usernameRegex.MatchString(rendered) {
+		addConfigError(user.file, user.node, "generated username '%s' (default from %s) is invalid; only letters, digits, '_', '.' and '-' are allowed",
+			rendered, layer.name)
+		return false
+	}
+
+	candidate = rendered
+	for i = 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", rendered, i)
+	}
+
+	taken[candidate] = true
+	user.UID = &candidate
+	user.sources["username"] = layer.name
+
+	glg.Debugf("generated username %s for object at %s:%d", candidate, user.file, user.node.Line)
+
+	return true
+}
+
+// generatedNote returns a note to display next to a username that was generated and not yet written to its file
+func generatedNote(user *posixAccount) string {
+	var (
+		source string
+		ok     bool
+	)
+
+	if source, ok = user.sources["username"]; ok {
+		return fmt.Sprintf(" (generated from default of %s, not yet written to file)", source)
+	}
+
+	return ""
+}
+
+// persistUsernames writes all generated usernames into the people files they belong to
+// once written, a username is explicitly set and will never change when other objects are added later on
+func persistUsernames() error {
+	var (
+		dn        string
+		i         int
+		user      *posixAccount
+		byFile    map[string][]*posixAccount
+		files     []string
+		file      string
+		ok        bool
+		generated int
+		err       error
+	)
+
+	byFile = make(map[string][]*posixAccount)
+
+	for dn = range localPeople {
+		for i = range localPeople[dn].Objects {
+			user = &localPeople[dn].Objects[i]
+
+			if _, ok = user.sources["username"]; ok {
+				if _, ok = byFile[user.file]; !ok {
+					files = append(files, user.file)
+				}
+
+				byFile[user.file] = append(byFile[user.file], user)
+			}
+		}
+	}
+
+	sort.Strings(files)
+
+	for _, file = range files {
+		if err = writeUsernames(file, byFile[file]); err != nil {
+			return fmt.Errorf("failed to write generated usernames to %s: %s", file, err.Error())
+		}
+
+		for _, user = range byFile[file] {
+			// the username is now part of the object
+			delete(user.sources, "username")
+			generated++
+		}
+
+		glg.Infof("wrote %d generated username(s) to %s", len(byFile[file]), file)
+	}
+
+	if generated > 0 {
+		glg.Warnf("%d generated username(s) were written to people files, make sure to commit them", generated)
+	}
+
+	return nil
+}
+
+// checkGeneratedUsernames reports every object whose username was generated but not yet written to its people file
+// such usernames may change when objects are added to people files later on which would recreate the account under
+// another name, so sync refuses to run until they are written
+func checkGeneratedUsernames() error {
+	var (
+		dn    string
+		i     int
+		users []*posixAccount
+		user  *posixAccount
+		ok    bool
+	)
+
+	for dn = range localPeople {
+		for i = range localPeople[dn].Objects {
+			if _, ok = localPeople[dn].Objects[i].sources["username"]; ok {
+				users = append(users, &localPeople[dn].Objects[i])
+			}
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].file != users[j].file {
+			return users[i].file < users[j].file
+		}
+
+		return users[i].node.Line < users[j].node.Line
+	})
+
+	for _, user = range users {
+		glg.Errorf("%s:%d: username %s is generated and not written to the file, it may change when objects are added",
+			user.file, user.node.Line, *user.UID)
+	}
+
+	if len(users) > 0 {
+		return fmt.Errorf("%d generated username(s) not written to people files, "+
+			"run validate --write-usernames and commit the result", len(users))
+	}
+
+	return nil
+}
+
+// writeUsernames adds the username to the given objects within a people file
+// The username is inserted as a new line in front of the first attribute of each object to keep formatting and
+// comments of the file untouched. Objects are identified by their position within the file which therefore must not
+// have changed since it was read.
+func writeUsernames(file string, users []*posixAccount) error {
+	var (
+		info    os.FileInfo
+		content []byte
+		doc     yaml.Node
+		objects *yaml.Node
+		item    *yaml.Node
+		user    *posixAccount
+		lines   []string
+		line    string
+		value   []byte
+		found   bool
+		err     error
+	)
+
+	if info, err = os.Stat(file); err != nil {
+		return err
+	}
+
+	if content, err = ioutil.ReadFile(file); err != nil {
+		return err
+	}
+
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Content) == 0 {
+		return fmt.Errorf("file is empty")
+	}
+
+	objects = yamlMappingValue(doc.Content[0], "objects")
+	if objects == nil {
+		return fmt.Errorf("no objects found")
+	}
+
+	lines = strings.Split(string(content), "\n")
+
+	// insert from the bottom up to keep line numbers of the remaining objects valid
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].node.Line > users[j].node.Line
+	})
+
+	for _, user = range users {
+		found = false
+
+		for _, item = range objects.Content {
+			if item.Line == user.node.Line && item.Column == user.node.Column {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("object of username %s not found, was the file changed?", *user.UID)
+		}
+
+		if item.Kind != yaml.MappingNode || item.Style&yaml.FlowStyle != 0 {
+			return fmt.Errorf("object of username %s must be written as block mapping", *user.UID)
+		}
+
+		// let yaml quote the value if required
+		if value, err = yaml.Marshal(*user.UID); err != nil {
+			return err
+		}
+
+		line = lines[item.Line-1]
+		lines[item.Line-1] = fmt.Sprintf("%susername: %s\n%s%s",
+			line[:item.Column-1],
+			strings.TrimSpace(string(value)),
+			strings.Repeat(" ", item.Column-1),
+			line[item.Column-1:])
+	}
+
+	return ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")), info.Mode())
+}
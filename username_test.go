@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestCheckGeneratedUsernames verifies usernames that are generated but not written to people files are an error
+func TestCheckGeneratedUsernames(t *testing.T) {
+	var (
+		dn        = "cn=devops,ou=people,dc=my-domain,dc=com"
+		explicit  = testAccount("johndoe")
+		generated = testAccount("jdoe")
+		err       error
+	)
+
+	generated.file = "people/devops"
+	generated.node = &yaml.Node{Line: 12}
+	generated.sources = map[string]string{"username": "default"}
+
+	localPeople = map[string]posixGroup{dn: posixGroup{dn: dn, Objects: []posixAccount{explicit}}}
+	defer func() {
+		localPeople = nil
+	}()
+
+	if err = checkGeneratedUsernames(); err != nil {
+		t.Errorf("expected no error for explicit usernames, got %s", err.Error())
+	}
+
+	localPeople[dn] = posixGroup{dn: dn, Objects: []posixAccount{explicit, generated}}
+	if checkGeneratedUsernames() == nil {
+		t.Errorf("expected error for generated username not written to the people file")
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configError describes a single problem found within a configuration file
+type configError struct {
+	file   string
+	line   int
+	column int
+	msg    string
+}
+
+// Error formats the problem the same way compilers do (file:line:column: message) so editors and hooks can parse it
+func (e *configError) Error() string {
+	switch {
+	case e.line == 0:
+		return fmt.Sprintf("%s: %s", e.file, e.msg)
+
+	case e.column == 0:
+		return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", e.file, e.line, e.column, e.msg)
+	}
+}
+
+// addConfigError records a problem found in file at the position of the given node
+// node can be nil when no position is known
+func addConfigError(file string, node *yaml.Node, format string, args ...interface{}) {
+	var e *configError
+
+	e = new(configError)
+	e.file = file
+	e.msg = fmt.Sprintf(format, args...)
+
+	if node != nil {
+		e.line = node.Line
+		e.column = node.Column
+	}
+
+	configErrors = append(configErrors, e)
+}
+
+// addYAMLErrorMessage records an error message produced by the yaml package which looks like "yaml: line 3: message"
+func addYAMLErrorMessage(file string, msg string) {
+	var (
+		e    *configError
+		line int
+		i    int
+	)
+
+	msg = strings.TrimPrefix(msg, "yaml: ")
+
+	e = new(configError)
+	e.file = file
+	e.msg = msg
+
+	if _, err := fmt.Sscanf(msg, "line %d:", &line); err == nil {
+		i = strings.Index(msg, ":")
+		e.line = line
+		e.msg = strings.TrimSpace(msg[i+1:])
+	}
+
+	configErrors = append(configErrors, e)
+}
+
+// printConfigErrors writes all collected configuration errors to stderr and returns an error if there were any
+func printConfigErrors() error {
+	var i int
+
+	if len(configErrors) == 0 {
+		return nil
+	}
+
+	for i = range configErrors {
+		fmt.Fprintln(os.Stderr, configErrors[i].Error())
+	}
+
+	return fmt.Errorf("found %d error(s) in configuration files", len(configErrors))
+}
+
+// decodeYAMLFile reads a YAML file and decodes it into out
+// Unknown fields and values of the wrong type are recorded as config errors instead of being silently ignored. The
+// returned node is the top level node of the document and can be used to look up positions of values. When the file
+// cannot be read or parsed at all, false is returned.
+func decodeYAMLFile(file string, out interface{}) (*yaml.Node, bool) {
+	var (
+		yamlFile []byte
+		doc      yaml.Node
+		root     *yaml.Node
+		err      error
+		before   int
+		lines    map[int]bool
+		i        int
+		typeErr  *yaml.TypeError
+		ok       bool
+		decoder  *yaml.Decoder
+	)
+
+	yamlFile, err = ioutil.ReadFile(file)
+	if err != nil {
+		addConfigError(file, nil, "failed to read file: %s", err.Error())
+		return nil, false
+	}
+
+	if err = yaml.Unmarshal(yamlFile, &doc); err != nil {
+		addYAMLErrorMessage(file, err.Error())
+		return nil, false
+	}
+
+	// empty documents decode into nothing
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, true
+	}
+
+	root = doc.Content[0]
+
+	// problems found by checkYAMLNode take precedence over the ones of the decoder as they are more precise
+	before = len(configErrors)
+	checkYAMLNode(file, root, reflect.TypeOf(out))
+
+	// remember lines that already have an error to not report the same problem twice
+	lines = make(map[int]bool)
+	for i = before; i < len(configErrors); i++ {
+		lines[configErrors[i].line] = true
+	}
+
+	decoder = yaml.NewDecoder(bytes.NewReader(yamlFile))
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(out); err != nil {
+		if typeErr, ok = err.(*yaml.TypeError); ok {
+			for i = range typeErr.Errors {
+				addYAMLErrorMessage(file, typeErr.Errors[i])
+
+				if lines[configErrors[len(configErrors)-1].line] {
+					// already reported by checkYAMLNode
+					configErrors = configErrors[:len(configErrors)-1]
+				}
+			}
+		} else {
+			addYAMLErrorMessage(file, err.Error())
+		}
+	}
+
+	return root, true
+}
+
+// checkYAMLNode recursively verifies that node can be decoded into a value of type t
+// Every mapping key must match a yaml tag of the target struct and scalars must match the kind of their target field.
+// The decoder finds unknown fields as well (KnownFields) but only reports them with a line number. This walk collects
+// all problems of a file in one pass with line and column and suggests the field that was probably meant. Types with
+// their own UnmarshalYAML (groupMember, attributeValues) are skipped as they report unknown fields and wrong types
+// themselves while decoding.
+func checkYAMLNode(file string, node *yaml.Node, t reflect.Type) {
+	var (
+		i     int
+		field reflect.StructField
+		ok    bool
+	)
+
+	if node == nil {
+		return
+	}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// explicit null values are fine for any field, they are treated as not set
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+
+	// types decoding themselves are responsible for their own validation
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			if node.Kind != yaml.ScalarNode {
+				addConfigError(file, node, "expected a timestamp")
+			}
+			return
+		}
+
+		if node.Kind != yaml.MappingNode {
+			addConfigError(file, node, "expected a mapping")
+			return
+		}
+
+		for i = 0; i+1 < len(node.Content); i += 2 {
+			if field, ok = yamlField(t, node.Content[i].Value); !ok {
+				addConfigError(file, node.Content[i], "unknown field %q%s", node.Content[i].Value,
+					yamlFieldSuggestion(t, node.Content[i].Value))
+				continue
+			}
+
+			checkYAMLNode(file, node.Content[i+1], field.Type)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			addConfigError(file, node, "expected a list")
+			return
+		}
+
+		for i = range node.Content {
+			checkYAMLNode(file, node.Content[i], t.Elem())
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			addConfigError(file, node, "expected a mapping")
+			return
+		}
+
+		for i = 0; i+1 < len(node.Content); i += 2 {
+			checkYAMLNode(file, node.Content[i+1], t.Elem())
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			addConfigError(file, node, "expected an integer but found %q", node.Value)
+		}
+
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			addConfigError(file, node, "expected true or false but found %q", node.Value)
+		}
+
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			addConfigError(file, node, "expected a string")
+		}
+	}
+}
+
+// yamlField returns the struct field of t that is decoded from the YAML key name
+func yamlField(t reflect.Type, name string) (reflect.StructField, bool) {
+	var i int
+
+	for i = 0; i < t.NumField(); i++ {
+		if yamlFieldName(t.Field(i)) == name {
+			return t.Field(i), true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// yamlFieldName returns the YAML key of a struct field or an empty string if the field is not decoded from YAML
+func yamlFieldName(field reflect.StructField) string {
+	var tag string
+
+	// unexported fields are never decoded
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	tag = strings.Split(field.Tag.Get("yaml"), ",")[0]
+
+	switch tag {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(field.Name)
+	}
+
+	return tag
+}
+
+// yamlFieldSuggestion returns a hint to the field that was most likely meant when name is unknown
+// this catches the common mistake of writing LDAP attribute names (userPassword) instead of config names (user_password)
+func yamlFieldSuggestion(t reflect.Type, name string) string {
+	var (
+		i         int
+		candidate string
+	)
+
+	for i = 0; i < t.NumField(); i++ {
+		candidate = yamlFieldName(t.Field(i))
+		if candidate == "" {
+			continue
+		}
+
+		if strings.ReplaceAll(strings.ToLower(candidate), "_", "") == strings.ReplaceAll(strings.ToLower(name), "_", "") {
+			return fmt.Sprintf(", did you mean %q?", candidate)
+		}
+	}
+
+	return ""
+}
+
+// yamlMappingValue returns the value node of key within a mapping node or nil if the key doesn't exist
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	var i int
+
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i = 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// yamlSequenceItem returns the item with index i of a sequence node or nil if it doesn't exist
+func yamlSequenceItem(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+		return nil
+	}
+
+	return node.Content[i]
+}
+
+// yamlPosition returns the value node of key for precise error positions and falls back to node itself
+func yamlPosition(node *yaml.Node, key string) *yaml.Node {
+	var value *yaml.Node
+
+	if value = yamlMappingValue(node, key); value != nil {
+		return value
+	}
+
+	return node
+}
+
+// yamlKeyPosition returns the key node of key within a mapping node for precise error positions and falls back to node
+func yamlKeyPosition(node *yaml.Node, key string) *yaml.Node {
+	var i int
+
+	if node == nil || node.Kind != yaml.MappingNode {
+		return node
+	}
+
+	for i = 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+
+	return node
+}
+
+// validateMail verifies that s is a plain mail address that can be delivered by any MTA
+func validateMail(s string) error {
+	var (
+		address *mail.Address
+		err     error
+	)
+
+	if !isASCII(s) {
+		return fmt.Errorf("contains non-ASCII characters, consider using transliteration")
+	}
+
+	if address, err = mail.ParseAddress(s); err != nil {
+		return fmt.Errorf("not a valid address (%s)", strings.TrimPrefix(err.Error(), "mail: "))
+	}
+
+	// only the address itself is allowed, no display names or angle brackets
+	if address.Name != "" || address.Address != s {
+		return fmt.Errorf("must be a plain address without name")
+	}
+
+	return nil
+}
+
+// validateHomeDir verifies that s is an absolute and normalized path usable as home directory
+func validateHomeDir(s string) error {
+	var (
+		r       rune
+		element string
+	)
+
+	if !strings.HasPrefix(s, "/") {
+		return fmt.Errorf("must be an absolute path")
+	}
+
+	for _, r = range s {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			return fmt.Errorf("must not contain whitespace or control characters")
+
+		case r == ':':
+			// colon separates fields in passwd
+			return fmt.Errorf("must not contain ':'")
+		}
+	}
+
+	// a trailing slash is fine, but empty elements in between usually come from empty template variables
+	for _, element = range strings.Split(strings.TrimSuffix(s[1:], "/"), "/") {
+		switch element {
+		case "":
+			if s != "/" {
+				return fmt.Errorf("must not contain empty path elements")
+			}
+
+		case ".", "..":
+			return fmt.Errorf("must not contain relative path elements")
+		}
+	}
+
+	return nil
+}
+
+// isASCII returns true if s only contains ASCII characters
+func isASCII(s string) bool {
+	var r rune
+
+	for _, r = range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDecodeYAMLFile verifies unknown fields are reported in one pass, including the ones within types that decode
+// themselves (groupMember, attributeValues)
+func TestDecodeYAMLFile(t *testing.T) {
+	var (
+		dir      string
+		file     string
+		group    groupOfNames
+		messages []string
+		expected []string
+		i        int
+		err      error
+	)
+
+	if dir, err = ioutil.TempDir("", "monban"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file = filepath.Join(dir, "developers")
+	err = ioutil.WriteFile(file, []byte(`cn: developers
+Description: typo
+attributes:
+  title:
+    nested: value
+members:
+  - johndoe
+  - member: janedoe
+    reson: typo
+  - member: peterpan
+    until: tomorrow
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configErrors = nil
+	defer func() {
+		configErrors = nil
+	}()
+
+	if _, ok := decodeYAMLFile(file, &group); !ok {
+		t.Fatalf("expected file to be parsed")
+	}
+
+	for i = range configErrors {
+		messages = append(messages, configErrors[i].Error())
+	}
+
+	expected = []string{
+		file + `:2:1: unknown field "Description", did you mean "description"?`,
+		file + ":5: expected a value or a list of values",
+		file + `:9: unknown field "reson" in member`,
+		file + ":11: until must be a date (2006-01-02) or a timestamp (2006-01-02T15:04:05Z)",
+	}
+
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected errors\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+}